@@ -0,0 +1,35 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"golang.org/x/text/language"
+
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/tokenizer"
+	// the collation filter is only in this module's token package
+	"github.com/strivewrt/bluge/analysis/token"
+)
+
+// NewCollationAnalyzer returns an analyzer producing the collation key
+// of the entire input as a single token
+func NewCollationAnalyzer(tag language.Tag, strength token.CollationStrength) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Tokenizer: tokenizer.NewSingleTokenTokenizer(),
+		TokenFilters: []analysis.TokenFilter{
+			token.NewCollationFilter(tag, strength),
+		},
+	}
+}
@@ -0,0 +1,90 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"sync"
+
+	"github.com/blugelabs/bluge/analysis"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// CollationStrength controls which differences between
+// strings are significant when comparing them
+type CollationStrength int
+
+const (
+	// CollationTertiary distinguishes base letters, accents, case and width
+	CollationTertiary CollationStrength = iota
+	// CollationSecondary distinguishes base letters and accents
+	CollationSecondary
+	// CollationPrimary distinguishes only base letters
+	CollationPrimary
+)
+
+func (s CollationStrength) options() []collate.Option {
+	switch s {
+	case CollationSecondary:
+		return []collate.Option{collate.IgnoreCase, collate.IgnoreWidth}
+	case CollationPrimary:
+		return []collate.Option{collate.Loose}
+	}
+	return nil
+}
+
+// CollationFilter replaces each term with its collation key for a locale,
+// comparing the keys as bytes orders the original terms as the locale does
+type CollationFilter struct {
+	collators sync.Pool
+}
+
+func NewCollationFilter(tag language.Tag, strength CollationStrength) *CollationFilter {
+	options := strength.options()
+	return &CollationFilter{
+		collators: sync.Pool{
+			New: func() interface{} {
+				return &collationKeyBuilder{
+					collator: collate.New(tag, options...),
+				}
+			},
+		},
+	}
+}
+
+// collationKeyBuilder holds the state needed to build keys,
+// which cannot be shared by concurrent users
+type collationKeyBuilder struct {
+	collator *collate.Collator
+	buf      collate.Buffer
+}
+
+// Key returns the collation key of the value
+func (f *CollationFilter) Key(value []byte) []byte {
+	builder := f.collators.Get().(*collationKeyBuilder)
+	key := builder.collator.Key(&builder.buf, value)
+	rv := make([]byte, len(key))
+	copy(rv, key)
+	builder.buf.Reset()
+	f.collators.Put(builder)
+	return rv
+}
+
+func (f *CollationFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		token.Term = f.Key(token.Term)
+	}
+	return input
+}
@@ -0,0 +1,95 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"github.com/blugelabs/bluge/analysis"
+)
+
+func TestCollationFilter(t *testing.T) {
+	tests := []struct {
+		tag      language.Tag
+		strength CollationStrength
+		input    []string
+		output   []string
+	}{
+		{
+			tag:    language.German,
+			input:  []string{"Zebra", "Äpfel", "Apfel", "Birne"},
+			output: []string{"Apfel", "Äpfel", "Birne", "Zebra"},
+		},
+		{
+			tag:    language.Swedish,
+			input:  []string{"Ödla", "Zebra", "Åsna", "Apa"},
+			output: []string{"Apa", "Zebra", "Åsna", "Ödla"},
+		},
+		{
+			tag:    language.Turkish,
+			input:  []string{"ipek", "ışık", "hasan", "jale"},
+			output: []string{"hasan", "ışık", "ipek", "jale"},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewCollationFilter(test.tag, test.strength)
+		var input analysis.TokenStream
+		for _, term := range test.input {
+			input = append(input, &analysis.Token{Term: []byte(term)})
+		}
+		keys := map[string]string{}
+		for i, token := range filter.Filter(input) {
+			keys[string(token.Term)] = test.input[i]
+		}
+		var sorted [][]byte
+		for key := range keys {
+			sorted = append(sorted, []byte(key))
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return bytes.Compare(sorted[i], sorted[j]) < 0
+		})
+		var got []string
+		for _, key := range sorted {
+			got = append(got, keys[string(key)])
+		}
+		if !reflect.DeepEqual(got, test.output) {
+			t.Errorf("%s: expected %v, got %v", test.tag, test.output, got)
+		}
+	}
+}
+
+func TestCollationFilterStrength(t *testing.T) {
+	primary := NewCollationFilter(language.German, CollationPrimary)
+	if !bytes.Equal(primary.Key([]byte("Äpfel")), primary.Key([]byte("apfel"))) {
+		t.Errorf("expected primary strength to ignore case and accents")
+	}
+	secondary := NewCollationFilter(language.German, CollationSecondary)
+	if !bytes.Equal(secondary.Key([]byte("Apfel")), secondary.Key([]byte("apfel"))) {
+		t.Errorf("expected secondary strength to ignore case")
+	}
+	if bytes.Equal(secondary.Key([]byte("Äpfel")), secondary.Key([]byte("Apfel"))) {
+		t.Errorf("expected secondary strength to distinguish accents")
+	}
+	tertiary := NewCollationFilter(language.German, CollationTertiary)
+	if bytes.Equal(tertiary.Key([]byte("Apfel")), tertiary.Key([]byte("apfel"))) {
+		t.Errorf("expected tertiary strength to distinguish case")
+	}
+}
@@ -73,6 +73,13 @@ func (config Config) DisableOptimizeDisjunctionUnadorned() Config {
 	return config
 }
 
+// WithFilterCache enables caching the documents matching filter queries
+// (see NewCachedFilterQuery), using at most the specified number of bytes
+func (config Config) WithFilterCache(maxBytes uint64) Config {
+	config.indexConfig = config.indexConfig.WithFilterCache(maxBytes)
+	return config
+}
+
 func (config Config) WithSearchStartFunc(f func(size uint64) error) Config {
 	config.SearchStartFunc = f
 	return config
@@ -0,0 +1,194 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/search"
+)
+
+// CountQuery returns the number of documents matching the query, without
+// scoring or collecting them.  Term, boolean, match all/none and the range
+// and other multi-term queries are evaluated entirely with bitmaps, any
+// other query is evaluated by iterating its matches.  It is not named
+// Count, as Count already returns the number of documents in the reader
+// and changing its signature would break its callers.
+func (r *Reader) CountQuery(ctx context.Context, q Query) (uint64, error) {
+	evaluator := &bitmapEvaluator{
+		ctx:      ctx,
+		snapshot: r.reader,
+		options: searchOptionsFromConfig(r.config, SearchOptions{
+			Score: "none",
+		}),
+	}
+	bitmaps, err := evaluator.queryBitmaps(q)
+	if err != nil {
+		return 0, err
+	}
+	return bitmaps.Count(), nil
+}
+
+// bitmapEvaluator computes the documents matching a query in a snapshot,
+// the bitmaps it returns may be modified by the caller
+type bitmapEvaluator struct {
+	ctx      context.Context
+	snapshot *index.Snapshot
+	options  search.SearcherOptions
+}
+
+func (e *bitmapEvaluator) queryBitmaps(q Query) (*index.SegmentBitmaps, error) {
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch q := q.(type) {
+	case *MatchAllQuery:
+		return e.snapshot.LiveBitmaps(), nil
+	case *MatchNoneQuery:
+		return e.snapshot.EmptyBitmaps(), nil
+	case *TermQuery:
+		field := q.field
+		if field == "" {
+			field = e.options.DefaultSearchField
+		}
+		return e.snapshot.TermBitmaps([]byte(q.term), field)
+	case *BooleanQuery:
+		if q.minShould <= 1 {
+			return e.booleanQueryBitmaps(q)
+		}
+	case *NumericRangeQuery, *DateRangeQuery, *TermRangeQuery, *PrefixQuery,
+		*WildcardQuery, *RegexpQuery, *FuzzyQuery:
+		return e.multiTermQueryBitmaps(q)
+	case *CachedFilterQuery:
+		bitmaps, err := q.filterBitmaps(e.snapshot, e.options)
+		if err != nil {
+			return nil, err
+		}
+		return bitmaps.Clone(), nil
+	}
+	return e.iterateQueryBitmaps(q)
+}
+
+// booleanQueryBitmaps follows the semantics of the boolean searcher,
+// should clauses are optional when there are must clauses, unless
+// a minimum number of should clauses is required
+func (e *bitmapEvaluator) booleanQueryBitmaps(q *BooleanQuery) (*index.SegmentBitmaps, error) {
+	var rv *index.SegmentBitmaps
+	for _, must := range q.musts {
+		bitmaps, err := e.queryBitmaps(must)
+		if err != nil {
+			return nil, err
+		}
+		if rv == nil {
+			rv = bitmaps
+		} else {
+			rv.And(bitmaps)
+		}
+	}
+
+	if len(q.shoulds) > 0 && (rv == nil || q.minShould > 0) {
+		shoulds, err := e.disjunctionBitmaps(q.shoulds)
+		if err != nil {
+			return nil, err
+		}
+		if rv == nil {
+			rv = shoulds
+		} else {
+			rv.And(shoulds)
+		}
+	}
+
+	if len(q.mustNots) > 0 {
+		if rv == nil {
+			rv = e.snapshot.LiveBitmaps()
+		}
+		mustNots, err := e.disjunctionBitmaps(q.mustNots)
+		if err != nil {
+			return nil, err
+		}
+		rv.AndNot(mustNots)
+	}
+
+	if rv == nil {
+		return e.snapshot.EmptyBitmaps(), nil
+	}
+	return rv, nil
+}
+
+func (e *bitmapEvaluator) disjunctionBitmaps(queries []Query) (*index.SegmentBitmaps, error) {
+	rv := e.snapshot.EmptyBitmaps()
+	for _, q := range queries {
+		bitmaps, err := e.queryBitmaps(q)
+		if err != nil {
+			return nil, err
+		}
+		rv.Or(bitmaps)
+	}
+	return rv, nil
+}
+
+// multiTermQueryBitmaps handles queries whose matches are exactly the union
+// of the postings of the terms they expand to.  The searcher is built only to
+// find those terms, the bitmap of each is OR'ed in as its postings are opened.
+func (e *bitmapEvaluator) multiTermQueryBitmaps(q Query) (*index.SegmentBitmaps, error) {
+	recorder := &bitmapRecordingReader{
+		Snapshot: e.snapshot,
+		bitmaps:  e.snapshot.EmptyBitmaps(),
+	}
+	searcher, err := q.Searcher(recorder, e.options)
+	if err != nil {
+		return nil, err
+	}
+	err = searcher.Close()
+	if err != nil {
+		return nil, err
+	}
+	return recorder.bitmaps, nil
+}
+
+// iterateQueryBitmaps is the fallback, collecting the matches of the searcher
+func (e *bitmapEvaluator) iterateQueryBitmaps(q Query) (*index.SegmentBitmaps, error) {
+	searcher, err := q.Searcher(e.snapshot, e.options)
+	if err != nil {
+		return nil, err
+	}
+	rv := e.snapshot.EmptyBitmaps()
+	err = visitMatches(e.ctx, searcher, func(_ *search.Context, dm *search.DocumentMatch) error {
+		rv.Add(dm.Number)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+type bitmapRecordingReader struct {
+	*index.Snapshot
+	bitmaps *index.SegmentBitmaps
+}
+
+func (r *bitmapRecordingReader) PostingsIterator(term []byte, field string, includeFreq, includeNorm,
+	includeTermVectors bool) (segment.PostingsIterator, error) {
+	bitmaps, err := r.Snapshot.TermBitmaps(term, field)
+	if err != nil {
+		return nil, err
+	}
+	r.bitmaps.Or(bitmaps)
+	return r.Snapshot.PostingsIterator(term, field, includeFreq, includeNorm, includeTermVectors)
+}
@@ -0,0 +1,128 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCountQuery(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	// several batches, to build several segments
+	for b := 0; b < 4; b++ {
+		batch := NewBatch()
+		for i := b * 25; i < (b+1)*25; i++ {
+			doc := NewDocument(fmt.Sprintf("doc-%03d", i)).
+				AddField(NewKeywordField("parity", []string{"even", "odd"}[i%2])).
+				AddField(NewKeywordField("tens", fmt.Sprintf("t%d", i/10))).
+				AddField(NewNumericField("n", float64(i))).
+				AddField(NewTextField("desc", fmt.Sprintf("number %d of many", i)))
+			batch.Update(doc.ID(), doc)
+		}
+		err = indexWriter.Batch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// deleted documents must not be counted
+	for i := 0; i < 100; i += 7 {
+		err = indexWriter.Delete(Identifier(fmt.Sprintf("doc-%03d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	tests := map[string]Query{
+		"all":     NewMatchAllQuery(),
+		"none":    NewMatchNoneQuery(),
+		"term":    NewTermQuery("even").SetField("parity"),
+		"missing": NewTermQuery("nope").SetField("parity"),
+		"range":   NewNumericRangeQuery(10, 60).SetField("n"),
+		"prefix":  NewPrefixQuery("t1").SetField("tens"),
+		"must": NewBooleanQuery().
+			AddMust(NewTermQuery("odd").SetField("parity")).
+			AddMust(NewNumericRangeInclusiveQuery(20, 80, true, true).SetField("n")),
+		"should": NewBooleanQuery().
+			AddShould(NewTermQuery("t1").SetField("tens")).
+			AddShould(NewTermQuery("t5").SetField("tens")),
+		"optional should": NewBooleanQuery().
+			AddMust(NewTermQuery("odd").SetField("parity")).
+			AddShould(NewTermQuery("t5").SetField("tens")),
+		"required should": NewBooleanQuery().
+			AddMust(NewTermQuery("odd").SetField("parity")).
+			AddShould(NewTermQuery("t5").SetField("tens")).
+			SetMinShould(1),
+		"must not": NewBooleanQuery().
+			AddMustNot(NewTermQuery("even").SetField("parity")),
+		"nested": NewBooleanQuery().
+			AddMust(NewBooleanQuery().
+				AddShould(NewTermQuery("t2").SetField("tens")).
+				AddShould(NewTermQuery("t3").SetField("tens"))).
+			AddMustNot(NewNumericRangeQuery(25, 28).SetField("n")),
+		"min should fallback": NewBooleanQuery().
+			AddShould(NewTermQuery("odd").SetField("parity")).
+			AddShould(NewTermQuery("t3").SetField("tens")).
+			AddShould(NewTermQuery("t4").SetField("tens")).
+			SetMinShould(2),
+		"match fallback": NewMatchQuery("number 42").SetField("desc"),
+	}
+
+	for name, q := range tests {
+		t.Run(name, func(t *testing.T) {
+			dmi, err := indexReader.Search(context.Background(), NewAllMatches(q))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect, err := countHits(dmi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, err := indexReader.CountQuery(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != uint64(expect) {
+				t.Errorf("expected %d, got %d", expect, count)
+			}
+		})
+	}
+}
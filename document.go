@@ -16,11 +16,19 @@ package bluge
 
 import (
 	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/search"
 )
 
 type Document struct {
 	fields    []Field
 	timestamp int64
+
+	// typeFields are the hidden fields recording the type of each
+	// stored field which opted in with StoreValueType
+	typeFields []Field
+	typeNames  map[string]struct{}
 }
 
 func NewDocument(id string) *Document {
@@ -41,6 +49,9 @@ func (d Document) Size() int {
 	for _, field := range d.fields {
 		sizeInBytes += field.Size()
 	}
+	for _, field := range d.typeFields {
+		sizeInBytes += field.Size()
+	}
 
 	return sizeInBytes
 }
@@ -51,8 +62,11 @@ func (d Document) ID() segment.Term {
 	return Identifier(d.fields[0].Value())
 }
 
+// AddField adds the field to the document, the type of its value is
+// stored if StoreValueType was used before adding it
 func (d *Document) AddField(f Field) *Document {
 	d.fields = append(d.fields, f)
+	d.addStoredFieldType(f)
 	return d
 }
 
@@ -102,4 +116,32 @@ func (d Document) EachField(vf segment.VisitField) {
 	for _, field := range d.fields {
 		vf(field)
 	}
+	for _, field := range d.typeFields {
+		vf(field)
+	}
+}
+
+// typedField is implemented by fields which know the type of their value
+type typedField interface {
+	FieldType() search.FieldType
+	storeType() bool
+}
+
+// addStoredFieldType builds the hidden field recording the type of the
+// field if it opted in with StoreValueType, once per field name, allowing
+// stored values to be decoded without knowing the mapping
+func (d *Document) addStoredFieldType(field Field) {
+	typed, ok := field.(typedField)
+	if !ok || !typed.storeType() || typed.FieldType() == search.FieldTypeUnknown {
+		return
+	}
+	if _, ok := d.typeNames[field.Name()]; ok {
+		return
+	}
+	if d.typeNames == nil {
+		d.typeNames = make(map[string]struct{})
+	}
+	d.typeNames[field.Name()] = struct{}{}
+	value := append([]byte{byte(typed.FieldType())}, field.Name()...)
+	d.typeFields = append(d.typeFields, NewStoredOnlyField(index.StoredFieldTypesField, value))
 }
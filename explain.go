@@ -0,0 +1,237 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// ErrDocumentNotFound is returned when no live document has the identifier
+var ErrDocumentNotFound = errors.New("document not found")
+
+// DocumentExplanation describes how a specific document relates to a query
+type DocumentExplanation struct {
+	// Matched reports whether the query matches the document
+	Matched bool
+	// Explanation is the score explanation, when the query matches
+	Explanation *search.Explanation
+	// Reason describes why the query does not match, when it does not
+	Reason *NoMatchReason
+}
+
+// NoMatchReason describes why a query, or one of its clauses,
+// does not match a document
+type NoMatchReason struct {
+	Query    string
+	Message  string
+	Children []*NoMatchReason
+}
+
+func (r *NoMatchReason) String() string {
+	var sb strings.Builder
+	r.format(&sb, 0)
+	return sb.String()
+}
+
+func (r *NoMatchReason) format(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%s: %s\n", strings.Repeat("  ", depth), r.Query, r.Message)
+	for _, child := range r.Children {
+		child.format(sb, depth+1)
+	}
+}
+
+// Explain explains the score of the document with the identifier for the
+// query, even if it would not rank in the top N of a search.  If the query
+// does not match the document, the reason describes which clauses failed.
+func (r *Reader) Explain(ctx context.Context, q Query, id segment.Term) (*DocumentExplanation, error) {
+	numbers, found, err := r.reader.DocumentNumbers([]segment.Term{id})
+	if err != nil {
+		return nil, err
+	}
+	if !found[0] {
+		return nil, ErrDocumentNotFound
+	}
+	e := &documentExplainer{
+		ctx:    ctx,
+		reader: r,
+		number: numbers[0],
+		options: searchOptionsFromConfig(r.config, SearchOptions{
+			ExplainScores: true,
+		}),
+	}
+	match, err := e.match(q)
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		return &DocumentExplanation{
+			Matched:     true,
+			Explanation: match.Explanation,
+		}, nil
+	}
+	reason, err := e.reason(q)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentExplanation{
+		Reason: reason,
+	}, nil
+}
+
+type documentExplainer struct {
+	ctx     context.Context
+	reader  *Reader
+	number  uint64
+	options search.SearcherOptions
+}
+
+// match positions the searcher of the query on the document,
+// returning the match if the query matches it, nil otherwise
+func (e *documentExplainer) match(q Query) (*search.DocumentMatch, error) {
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+	s, err := q.Searcher(e.reader.reader, e.options)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+	sctx := search.NewSearchContext(s.DocumentMatchPoolSize(), 0)
+	match, err := s.Advance(sctx, e.number)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil || match.Number != e.number {
+		return nil, nil
+	}
+	return match, nil
+}
+
+func (e *documentExplainer) matches(q Query) (bool, error) {
+	match, err := e.match(q)
+	return match != nil, err
+}
+
+// reason describes why the query, known not to match, does not match
+func (e *documentExplainer) reason(q Query) (*NoMatchReason, error) {
+	rv := &NoMatchReason{
+		Query:   queryTypeName(q),
+		Message: "does not match",
+	}
+	switch q := q.(type) {
+	case *BooleanQuery:
+		return e.booleanReason(q, rv)
+	case *TermQuery:
+		rv.Message = fmt.Sprintf("term %q not found in field %q", q.term, e.fieldOrDefault(q.field))
+	case *MatchNoneQuery:
+		rv.Message = "matches no documents"
+	case *CachedFilterQuery:
+		child, err := e.reason(q.query)
+		if err != nil {
+			return nil, err
+		}
+		rv.Children = append(rv.Children, child)
+	}
+	return rv, nil
+}
+
+func (e *documentExplainer) booleanReason(q *BooleanQuery, rv *NoMatchReason) (*NoMatchReason, error) {
+	var failed []string
+	for _, must := range q.musts {
+		ok, err := e.matches(must)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			child, err := e.reason(must)
+			if err != nil {
+				return nil, err
+			}
+			child.Message = "must clause " + child.Message
+			rv.Children = append(rv.Children, child)
+			failed = append(failed, "must")
+		}
+	}
+
+	for _, mustNot := range q.mustNots {
+		ok, err := e.matches(mustNot)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rv.Children = append(rv.Children, &NoMatchReason{
+				Query:   queryTypeName(mustNot),
+				Message: "must not clause matches",
+			})
+			failed = append(failed, "must not")
+		}
+	}
+
+	minShould := q.minShould
+	if minShould == 0 && len(q.musts) == 0 && len(q.shoulds) > 0 {
+		// without must clauses, at least one should clause is required
+		minShould = 1
+	}
+	if minShould > 0 {
+		var matched int
+		var reasons []*NoMatchReason
+		for _, should := range q.shoulds {
+			ok, err := e.matches(should)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched++
+				continue
+			}
+			child, err := e.reason(should)
+			if err != nil {
+				return nil, err
+			}
+			child.Message = "should clause " + child.Message
+			reasons = append(reasons, child)
+		}
+		if matched < minShould {
+			rv.Children = append(rv.Children, &NoMatchReason{
+				Query: "should",
+				Message: fmt.Sprintf("%d of %d should clauses match, %d required",
+					matched, len(q.shoulds), minShould),
+				Children: reasons,
+			})
+			failed = append(failed, "should")
+		}
+	}
+
+	if len(failed) > 0 {
+		rv.Message = "failed " + strings.Join(failed, ", ") + " clauses"
+	}
+	return rv, nil
+}
+
+func (e *documentExplainer) fieldOrDefault(field string) string {
+	if field == "" {
+		return e.options.DefaultSearchField
+	}
+	return field
+}
@@ -0,0 +1,112 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReaderExplain(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	indexWriter, err := OpenWriter(DefaultConfig(tmpIndexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	batch := NewBatch()
+	for _, doc := range []*Document{
+		NewDocument("a").
+			AddField(NewKeywordField("color", "red")).
+			AddField(NewKeywordField("size", "small")),
+		NewDocument("b").
+			AddField(NewKeywordField("color", "blue")).
+			AddField(NewKeywordField("size", "large")),
+	} {
+		batch.Update(doc.ID(), doc)
+	}
+	err = indexWriter.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	q := NewBooleanQuery().
+		AddMust(NewTermQuery("red").SetField("color")).
+		AddMustNot(NewTermQuery("large").SetField("size"))
+
+	explanation, err := indexReader.Explain(context.Background(), q, Identifier("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Matched || explanation.Explanation == nil || explanation.Explanation.Value <= 0 {
+		t.Errorf("expected a to match with an explanation, got %+v", explanation)
+	}
+
+	explanation, err = indexReader.Explain(context.Background(), q, Identifier("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Matched || explanation.Reason == nil {
+		t.Fatalf("expected b to not match with a reason, got %+v", explanation)
+	}
+	reason := explanation.Reason
+	if reason.Message != "failed must, must not clauses" || len(reason.Children) != 2 {
+		t.Fatalf("unexpected reason:\n%s", reason)
+	}
+	if reason.Children[0].Message != `must clause term "red" not found in field "color"` {
+		t.Errorf("unexpected must reason: %s", reason.Children[0].Message)
+	}
+	if reason.Children[1].Message != "must not clause matches" {
+		t.Errorf("unexpected must not reason: %s", reason.Children[1].Message)
+	}
+
+	shoulds := NewBooleanQuery().
+		AddShould(NewTermQuery("green").SetField("color")).
+		AddShould(NewTermQuery("medium").SetField("size"))
+	explanation, err = indexReader.Explain(context.Background(), shoulds, Identifier("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Matched || len(explanation.Reason.Children) != 1 ||
+		explanation.Reason.Children[0].Message != "0 of 2 should clauses match, 1 required" ||
+		len(explanation.Reason.Children[0].Children) != 2 {
+		t.Errorf("unexpected should reason:\n%s", explanation.Reason)
+	}
+
+	_, err = indexReader.Explain(context.Background(), q, Identifier("missing"))
+	if !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected document not found, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/collector"
+)
+
+// ExportRequest describes a full export of the documents matching a query.
+// Matches are visited in document number order and are not scored.
+type ExportRequest struct {
+	query          Query
+	storedFields   []string
+	docValueFields []string
+}
+
+func NewExportRequest(q Query) *ExportRequest {
+	return &ExportRequest{
+		query: q,
+	}
+}
+
+// WithStoredFields selects the stored fields loaded for each match
+func (e *ExportRequest) WithStoredFields(fields ...string) *ExportRequest {
+	e.storedFields = append(e.storedFields, fields...)
+	return e
+}
+
+// WithDocValues selects the document values loaded for each match
+func (e *ExportRequest) WithDocValues(fields ...string) *ExportRequest {
+	e.docValueFields = append(e.docValueFields, fields...)
+	return e
+}
+
+// ExportedMatch is a single document produced by an export
+type ExportedMatch struct {
+	Number       uint64
+	StoredFields map[string][][]byte
+	DocValues    map[string][][]byte
+}
+
+// ExportVisitor is invoked for each exported match, returning
+// an error stops the export and is returned to the caller
+type ExportVisitor func(match *ExportedMatch) error
+
+// storedFieldSet returns the distinct stored fields to export
+func (e *ExportRequest) storedFieldSet() map[string]struct{} {
+	rv := make(map[string]struct{}, len(e.storedFields))
+	for _, field := range e.storedFields {
+		rv[field] = struct{}{}
+	}
+	return rv
+}
+
+// exportMatch loads the stored fields in the set, the values of each field
+// are stored together, so the visit stops at the first other field once
+// values of all of them have been seen
+func (e *ExportRequest) exportMatch(dm *search.DocumentMatch, storedFields map[string]struct{}) (*ExportedMatch, error) {
+	rv := &ExportedMatch{
+		Number: dm.Number,
+	}
+	if len(storedFields) > 0 {
+		rv.StoredFields = make(map[string][][]byte, len(storedFields))
+		err := dm.VisitStoredFields(func(field string, value []byte) bool {
+			if _, ok := storedFields[field]; !ok {
+				return len(rv.StoredFields) < len(storedFields)
+			}
+			valCopy := make([]byte, len(value))
+			copy(valCopy, value)
+			rv.StoredFields[field] = append(rv.StoredFields[field], valCopy)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(e.docValueFields) > 0 {
+		rv.DocValues = make(map[string][][]byte, len(e.docValueFields))
+		for _, field := range e.docValueFields {
+			for _, value := range dm.DocValues(field) {
+				valCopy := make([]byte, len(value))
+				copy(valCopy, value)
+				rv.DocValues[field] = append(rv.DocValues[field], valCopy)
+			}
+		}
+	}
+	return rv, nil
+}
+
+// Export visits every document matching the request, one at a time, in
+// document number order.  Only the current match is held in memory, so
+// this is suitable for result sets of any size.
+func (r *Reader) Export(ctx context.Context, req *ExportRequest, visitor ExportVisitor) error {
+	searcher, err := req.query.Searcher(r.reader, searchOptionsFromConfig(r.config, SearchOptions{
+		Score: "none",
+	}))
+	if err != nil {
+		return err
+	}
+
+	storedFields := req.storedFieldSet()
+	coll := collector.NewStreamCollector(func(dm *search.DocumentMatch) error {
+		match, err := req.exportMatch(dm, storedFields)
+		if err != nil {
+			return err
+		}
+		return visitor(match)
+	}, req.docValueFields...)
+
+	_, err = coll.Collect(ctx, nil, searcher)
+	return err
+}
+
+// ExportChan is like Export, but delivers matches on a channel with the
+// specified buffer size.  The export only proceeds as fast as the channel
+// is drained.  The match channel is closed when the export ends, after which
+// the error channel yields the outcome.  Callers which stop reading early
+// must cancel the context to release the export.
+func (r *Reader) ExportChan(ctx context.Context, req *ExportRequest, bufferSize int) (
+	matches <-chan *ExportedMatch, errs <-chan error) {
+	matchCh := make(chan *ExportedMatch, bufferSize)
+	errCh := make(chan error, 1)
+	go func() {
+		err := r.Export(ctx, req, func(match *ExportedMatch) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			select {
+			case matchCh <- match:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(matchCh)
+		errCh <- err
+		close(errCh)
+	}()
+	return matchCh, errCh
+}
@@ -0,0 +1,157 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	batch := NewBatch()
+	for i := 0; i < 100; i++ {
+		doc := NewDocument(fmt.Sprintf("%03d", i)).
+			AddField(NewKeywordField("type", "even").StoreValue().Sortable()).
+			AddField(NewKeywordField("other", "x").StoreValue())
+		if i%2 == 1 {
+			doc = NewDocument(fmt.Sprintf("%03d", i)).
+				AddField(NewKeywordField("type", "odd").StoreValue().Sortable())
+		}
+		batch.Update(doc.ID(), doc)
+	}
+	err = indexWriter.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatalf("error getting index reader: %v", err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	req := NewExportRequest(NewTermQuery("even").SetField("type")).
+		WithStoredFields("_id").
+		WithDocValues("type")
+
+	var count int
+	var lastNumber uint64
+	err = indexReader.Export(context.Background(), req, func(match *ExportedMatch) error {
+		if count > 0 && match.Number <= lastNumber {
+			t.Errorf("expected increasing document numbers, got %d after %d", match.Number, lastNumber)
+		}
+		lastNumber = match.Number
+		count++
+		if len(match.StoredFields) != 1 || len(match.StoredFields["_id"]) != 1 {
+			t.Errorf("expected only _id stored field, got %v", match.StoredFields)
+		}
+		if string(match.DocValues["type"][0]) != "even" {
+			t.Errorf("expected doc value even, got %v", match.DocValues["type"])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 50 {
+		t.Errorf("expected 50 exported matches, got %d", count)
+	}
+
+	// consume part of the channel, then cancel
+	ctx, cancel := context.WithCancel(context.Background())
+	matches, errs := indexReader.ExportChan(ctx, NewExportRequest(NewMatchAllQuery()), 1)
+	count = 0
+	for range matches {
+		count++
+		if count == 10 {
+			cancel()
+			break
+		}
+	}
+	for range matches {
+		// drain anything already buffered
+	}
+	err = <-errs
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	cancel()
+}
+
+func TestExportStoredFields(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	doc := NewDocument("a").
+		AddField(NewKeywordField("tag", "x").StoreValue()).
+		AddField(NewKeywordField("name", "alice").StoreValue()).
+		AddField(NewKeywordField("tag", "y").StoreValue()).
+		AddField(NewKeywordField("other", "z").StoreValue())
+	writer := openTestWriterWithDocs(t, tmpIndexPath, doc)
+	defer func() {
+		_ = writer.Close()
+	}()
+	reader, err := writer.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// every value of the requested fields is loaded, however they are ordered
+	req := NewExportRequest(NewMatchAllQuery()).WithStoredFields("tag", "name", "tag")
+	var count int
+	err = reader.Export(context.Background(), req, func(match *ExportedMatch) error {
+		count++
+		if len(match.StoredFields) != 2 {
+			t.Errorf("expected 2 stored fields, got %v", match.StoredFields)
+		}
+		if len(match.StoredFields["tag"]) != 2 {
+			t.Errorf("expected 2 tags, got %q", match.StoredFields["tag"])
+		}
+		if len(match.StoredFields["name"]) != 1 || string(match.StoredFields["name"][0]) != "alice" {
+			t.Errorf("expected name alice, got %q", match.StoredFields["name"])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 exported match, got %d", count)
+	}
+}
@@ -16,12 +16,17 @@ package bluge
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
+	"golang.org/x/text/language"
+
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/analysis/analyzer"
+	"github.com/blugelabs/bluge/analysis/token"
 	"github.com/blugelabs/bluge/numeric"
 	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
 	segment "github.com/strivewrt/bluge_segment_api"
 )
 
@@ -34,6 +39,7 @@ const (
 	HighlightMatches
 	Sortable
 	Aggregatable
+	StoreType
 )
 
 func (o FieldOptions) Index() bool {
@@ -52,6 +58,10 @@ func (o FieldOptions) IndexDocValues() bool {
 	return o&Sortable != 0 || o&Aggregatable != 0
 }
 
+func (o FieldOptions) storeType() bool {
+	return o&Store != 0 && o&StoreType != 0
+}
+
 type Field interface {
 	segment.Field
 
@@ -72,6 +82,7 @@ type TermField struct {
 	analyzedTokenFreqs   analysis.TokenFrequencies
 	analyzer             Analyzer
 	positionIncrementGap int
+	fieldType            search.FieldType
 }
 
 func (b *TermField) PositionIncrementGap() int {
@@ -109,11 +120,23 @@ func (b *TermField) NumPlainTextBytes() int {
 	return b.numPlainTextBytes
 }
 
+// FieldType returns the type recorded for the stored value of the field
+func (b *TermField) FieldType() search.FieldType {
+	return b.fieldType
+}
+
 func (b *TermField) StoreValue() *TermField {
 	b.FieldOptions |= Store
 	return b
 }
 
+// StoreValueType stores the value along with its type, so that it can
+// be decoded by StoredDocument without knowing how it was indexed
+func (b *TermField) StoreValueType() *TermField {
+	b.FieldOptions |= Store | StoreType
+	return b
+}
+
 func (b *TermField) Sortable() *TermField {
 	b.FieldOptions |= Sortable
 	return b
@@ -189,22 +212,22 @@ type Analyzer interface {
 var standardAnalyzer = analyzer.NewStandardAnalyzer()
 
 func NewKeywordField(name, value string) *TermField {
-	return newTextField(name, []byte(value), nil)
+	return newTextField(name, []byte(value), nil, search.FieldTypeText)
 }
 
 func NewKeywordFieldBytes(name string, value []byte) *TermField {
-	return newTextField(name, value, nil)
+	return newTextField(name, value, nil, search.FieldTypeBytes)
 }
 
 func NewTextField(name, value string) *TermField {
-	return newTextField(name, []byte(value), standardAnalyzer)
+	return newTextField(name, []byte(value), standardAnalyzer, search.FieldTypeText)
 }
 
 func NewTextFieldBytes(name string, value []byte) *TermField {
-	return newTextField(name, value, standardAnalyzer)
+	return newTextField(name, value, standardAnalyzer, search.FieldTypeBytes)
 }
 
-func newTextField(name string, value []byte, fieldAnalyzer Analyzer) *TermField {
+func newTextField(name string, value []byte, fieldAnalyzer Analyzer, fieldType search.FieldType) *TermField {
 	return &TermField{
 		FieldOptions:         defaultTextIndexingOptions,
 		name:                 name,
@@ -212,9 +235,41 @@ func newTextField(name string, value []byte, fieldAnalyzer Analyzer) *TermField
 		numPlainTextBytes:    len(value),
 		analyzer:             fieldAnalyzer,
 		positionIncrementGap: 100,
+		fieldType:            fieldType,
 	}
 }
 
+const defaultCollationIndexingOptions = Index | Sortable
+
+type collationAnalyzerKey struct {
+	tag      string
+	strength token.CollationStrength
+}
+
+// collationAnalyzers are shared by all fields using the same
+// locale and strength, building a collator is not cheap
+var collationAnalyzers sync.Map
+
+func collationAnalyzer(tag language.Tag, strength token.CollationStrength) Analyzer {
+	key := collationAnalyzerKey{tag: tag.String(), strength: strength}
+	if rv, ok := collationAnalyzers.Load(key); ok {
+		return rv.(Analyzer)
+	}
+	rv, _ := collationAnalyzers.LoadOrStore(key, analyzer.NewCollationAnalyzer(tag, strength))
+	return rv.(Analyzer)
+}
+
+// NewCollationKeywordField creates a keyword field which indexes the
+// collation key of the value for the locale, instead of the value itself.
+// Sorting by this field orders the values as the locale does, at the chosen
+// strength.  Term queries for the field must use the same collation key,
+// the original value is what is stored, if the field is stored.
+func NewCollationKeywordField(name, value string, tag language.Tag, strength token.CollationStrength) *TermField {
+	rv := newTextField(name, []byte(value), collationAnalyzer(tag, strength), search.FieldTypeText)
+	rv.FieldOptions = defaultCollationIndexingOptions
+	return rv
+}
+
 const defaultNumericIndexingOptions = Index | Sortable | Aggregatable
 
 const defaultNumericPrecisionStep uint = 4
@@ -292,6 +347,7 @@ func newNumericFieldWithIndexingOptions(name string, number float64, options Fie
 			shiftBy:   defaultNumericPrecisionStep,
 		},
 		positionIncrementGap: 100,
+		fieldType:            search.FieldTypeNumeric,
 	}
 }
 
@@ -320,6 +376,7 @@ func NewDateTimeField(name string, dt time.Time) *TermField {
 			shiftBy:   defaultDateTimePrecisionStep,
 		},
 		positionIncrementGap: 100,
+		fieldType:            search.FieldTypeDateTime,
 	}
 }
 
@@ -346,6 +403,7 @@ func NewGeoPointField(name string, lon, lat float64) *TermField {
 			shiftBy:   geoPrecisionStep,
 		},
 		positionIncrementGap: 100,
+		fieldType:            search.FieldTypeGeoPoint,
 	}
 }
 
@@ -461,5 +519,6 @@ func NewStoredOnlyField(name string, value []byte) *TermField {
 		name:              name,
 		value:             value,
 		numPlainTextBytes: len(value),
+		fieldType:         search.FieldTypeBytes,
 	}
 }
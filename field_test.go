@@ -15,7 +15,14 @@
 package bluge
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"golang.org/x/text/language"
+
+	"github.com/blugelabs/bluge/analysis/token"
 )
 
 func TestIndexingOptions(t *testing.T) {
@@ -234,3 +241,69 @@ func TestGeoPointField(t *testing.T) {
 		t.Errorf("expected 9 token freqs, got %d", len(tokenFreqs))
 	}
 }
+
+func TestCollationKeywordFieldSort(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	indexWriter, err := OpenWriter(DefaultConfig(tmpIndexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	batch := NewBatch()
+	for i, name := range []string{"Ödla", "Zebra", "Åsna", "Apa", "Bäver"} {
+		doc := NewDocument(fmt.Sprintf("%d", i)).
+			AddField(NewCollationKeywordField("name", name, language.Swedish, token.CollationTertiary).
+				StoreValue())
+		batch.Update(doc.ID(), doc)
+	}
+	err = indexWriter.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	req := NewTopNSearch(10, NewMatchAllQuery()).SortBy([]string{"name"})
+	dmi, err := indexReader.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		err = next.VisitStoredFields(func(field string, value []byte) bool {
+			if field == "name" {
+				got = append(got, string(value))
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"Apa", "Bäver", "Zebra", "Åsna", "Ödla"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+}
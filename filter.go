@@ -0,0 +1,214 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/searcher"
+	"github.com/blugelabs/bluge/search/similarity"
+)
+
+// CachedFilterQuery wraps a query used only to filter documents, the
+// documents it matches in each segment are remembered in the filter cache
+// of the index (see Config.WithFilterCache), and reused by later searches
+// until the segment is merged away.  Matches are not scored by the wrapped
+// query, each receives the constant boost of this query.
+type CachedFilterQuery struct {
+	query Query
+	key   string
+	boost *boost
+}
+
+// NewCachedFilterQuery creates a new Query caching the documents matched
+// by the provided query.  Term, match all/none, range, prefix and boolean
+// queries composed of these are identified automatically, other queries
+// are only cached once a key is set with SetKey.
+func NewCachedFilterQuery(q Query) *CachedFilterQuery {
+	return &CachedFilterQuery{
+		query: q,
+	}
+}
+
+// SetKey sets the key identifying the filter in the cache, queries sharing
+// a key must match the same documents
+func (q *CachedFilterQuery) SetKey(key string) *CachedFilterQuery {
+	q.key = key
+	return q
+}
+
+func (q *CachedFilterQuery) SetBoost(b float64) *CachedFilterQuery {
+	boostVal := boost(b)
+	q.boost = &boostVal
+	return q
+}
+
+func (q *CachedFilterQuery) Boost() float64 {
+	return q.boost.Value()
+}
+
+func (q *CachedFilterQuery) Query() Query {
+	return q.query
+}
+
+func (q *CachedFilterQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	snapshot := unwrapSnapshot(i)
+	if snapshot == nil {
+		return q.query.Searcher(i, options)
+	}
+	if _, ok := q.cacheKey(options); !ok {
+		return q.query.Searcher(i, options)
+	}
+	bitmaps, err := q.filterBitmaps(snapshot, options)
+	if err != nil {
+		return nil, err
+	}
+	return searcher.NewPostingsSearcher(i, bitmaps.PostingsIterator(),
+		similarity.ConstantScorer(q.boost.Value()), options)
+}
+
+func (q *CachedFilterQuery) cacheKey(options search.SearcherOptions) (string, bool) {
+	if q.key != "" {
+		return "key:" + q.key, true
+	}
+	return filterKey(q.query, options.DefaultSearchField)
+}
+
+// filterBitmaps returns the documents matched by the wrapped query, the
+// result is shared with the cache and must not be modified
+func (q *CachedFilterQuery) filterBitmaps(snapshot *index.Snapshot,
+	options search.SearcherOptions) (*index.SegmentBitmaps, error) {
+	options.Score = "none"
+	options.Explain = false
+	options.IncludeTermVectors = false
+	compute := func(s *index.Snapshot) (*index.SegmentBitmaps, error) {
+		evaluator := &bitmapEvaluator{
+			ctx:      context.Background(),
+			snapshot: s,
+			options:  options,
+		}
+		return evaluator.queryBitmaps(q.query)
+	}
+	key, ok := q.cacheKey(options)
+	if !ok {
+		return compute(snapshot)
+	}
+	return snapshot.FilterBitmaps(key, compute)
+}
+
+// readerUnwrapper is implemented by the readers wrapping another reader
+// to observe or adjust how searchers use it
+type readerUnwrapper interface {
+	Unwrap() search.Reader
+}
+
+// unwrapSnapshot returns the index snapshot underlying the reader,
+// looking through any wrapping readers, or nil if there is none
+func unwrapSnapshot(reader search.MatchReader) *index.Snapshot {
+	for {
+		switch r := reader.(type) {
+		case *index.Snapshot:
+			return r
+		case readerUnwrapper:
+			reader = r.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// filterKey returns a canonical description of the documents matched by
+// the query, or false if the query cannot be described
+func filterKey(q Query, defaultField string) (string, bool) {
+	fieldOrDefault := func(field string) string {
+		if field == "" {
+			return defaultField
+		}
+		return field
+	}
+	switch q := q.(type) {
+	case *MatchAllQuery:
+		return "all", true
+	case *MatchNoneQuery:
+		return "none", true
+	case *TermQuery:
+		return fmt.Sprintf("term(%q,%q)", fieldOrDefault(q.field), q.term), true
+	case *PrefixQuery:
+		return fmt.Sprintf("prefix(%q,%q)", fieldOrDefault(q.field), q.prefix), true
+	case *TermRangeQuery:
+		return fmt.Sprintf("termRange(%q,%q,%t,%q,%t)", fieldOrDefault(q.field),
+			q.min, q.inclusiveMin, q.max, q.inclusiveMax), true
+	case *NumericRangeQuery:
+		return fmt.Sprintf("numericRange(%q,%s,%t,%s,%t)", fieldOrDefault(q.field),
+			formatFilterFloat(q.min), q.inclusiveMin, formatFilterFloat(q.max), q.inclusiveMax), true
+	case *DateRangeQuery:
+		return fmt.Sprintf("dateRange(%q,%s,%t,%s,%t)", fieldOrDefault(q.field),
+			formatFilterTime(q.start), q.inclusiveStart, formatFilterTime(q.end), q.inclusiveEnd), true
+	case *CachedFilterQuery:
+		if q.key != "" {
+			return "key:" + q.key, true
+		}
+		return filterKey(q.query, defaultField)
+	case *BooleanQuery:
+		musts, ok := filterKeys(q.musts, defaultField)
+		if !ok {
+			return "", false
+		}
+		shoulds, ok := filterKeys(q.shoulds, defaultField)
+		if !ok {
+			return "", false
+		}
+		mustNots, ok := filterKeys(q.mustNots, defaultField)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("bool(must[%s],should[%s],mustNot[%s],%d)",
+			musts, shoulds, mustNots, q.minShould), true
+	}
+	return "", false
+}
+
+// filterKeys describes a list of clauses, the order of which does not
+// change the documents matched
+func filterKeys(queries []Query, defaultField string) (string, bool) {
+	keys := make([]string, 0, len(queries))
+	for _, q := range queries {
+		key, ok := filterKey(q, defaultField)
+		if !ok {
+			return "", false
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ","), true
+}
+
+func formatFilterFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func formatFilterTime(t time.Time) string {
+	if t.IsZero() {
+		return "*"
+	}
+	return t.Format(time.RFC3339Nano)
+}
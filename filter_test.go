@@ -0,0 +1,290 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+func TestCachedFilterQuery(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath).WithFilterCache(1 << 20)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	for b := 0; b < 4; b++ {
+		batch := NewBatch()
+		for i := b * 25; i < (b+1)*25; i++ {
+			doc := NewDocument(fmt.Sprintf("doc-%03d", i)).
+				AddField(NewKeywordField("parity", []string{"even", "odd"}[i%2])).
+				AddField(NewKeywordField("tens", fmt.Sprintf("t%d", i/10))).
+				AddField(NewNumericField("n", float64(i)))
+			batch.Update(doc.ID(), doc)
+		}
+		err = indexWriter.Batch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filters := map[string]Query{
+		"term":  NewTermQuery("even").SetField("parity"),
+		"range": NewNumericRangeQuery(10, 60).SetField("n"),
+		"bool": NewBooleanQuery().
+			AddShould(NewTermQuery("t1").SetField("tens")).
+			AddShould(NewTermQuery("t5").SetField("tens")).
+			AddMustNot(NewTermQuery("odd").SetField("parity")),
+	}
+
+	matchIDs := func(r *Reader, q Query) []string {
+		dmi, err := r.Search(context.Background(), NewAllMatches(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rv []string
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			err = next.VisitStoredFields(func(field string, value []byte) bool {
+				if field == "_id" {
+					rv = append(rv, string(value))
+				}
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(rv)
+		return rv
+	}
+
+	check := func(r *Reader) {
+		for name, q := range filters {
+			expect := matchIDs(r, q)
+			got := matchIDs(r, NewCachedFilterQuery(q))
+			if !reflect.DeepEqual(expect, got) {
+				t.Errorf("%s: expected %v, got %v", name, expect, got)
+			}
+			count, err := r.CountQuery(context.Background(), NewCachedFilterQuery(q))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != uint64(len(expect)) {
+				t.Errorf("%s: expected count %d, got %d", name, len(expect), count)
+			}
+		}
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(indexReader)
+	err = indexReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := indexWriter.chill.FilterCacheStats()
+	if stats.TotFilterCacheMisses == 0 {
+		t.Errorf("expected filter cache misses")
+	}
+	if stats.TotFilterCacheHits == 0 {
+		t.Errorf("expected filter cache hits")
+	}
+	if stats.CurFilterCacheBytes == 0 {
+		t.Errorf("expected filter cache to be in use")
+	}
+
+	// cached filters must not match documents deleted since
+	for i := 0; i < 100; i += 3 {
+		err = indexWriter.Delete(Identifier(fmt.Sprintf("doc-%03d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexReader, err = indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+	check(indexReader)
+}
+
+func TestCachedFilterQueryScore(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath).WithFilterCache(1 << 20)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	doc := NewDocument("a").AddField(NewKeywordField("tag", "x"))
+	err = indexWriter.Update(doc.ID(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	q := NewCachedFilterQuery(NewTermQuery("x").SetField("tag")).SetBoost(2.5)
+	dmi, err := indexReader.Search(context.Background(), NewTopNSearch(10, q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits []*search.DocumentMatch
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		hits = append(hits, next)
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("expected 1 hit, got %d", len(hits))
+	}
+	if hits[0].Score != 2.5 {
+		t.Errorf("expected constant score 2.5, got %f", hits[0].Score)
+	}
+}
+
+func TestFilterKey(t *testing.T) {
+	a := NewBooleanQuery().
+		AddMust(NewTermQuery("x").SetField("f")).
+		AddMust(NewNumericRangeQuery(1, 2).SetField("n"))
+	b := NewBooleanQuery().
+		AddMust(NewNumericRangeQuery(1, 2).SetField("n")).
+		AddMust(NewTermQuery("x").SetField("f"))
+	keyA, ok := filterKey(a, "_all")
+	if !ok {
+		t.Fatal("expected key")
+	}
+	keyB, _ := filterKey(b, "_all")
+	if keyA != keyB {
+		t.Errorf("expected clause order to not matter, got %s and %s", keyA, keyB)
+	}
+
+	keyDefault, _ := filterKey(NewTermQuery("x"), "f")
+	keyField, _ := filterKey(NewTermQuery("x").SetField("f"), "_all")
+	if keyDefault != keyField {
+		t.Errorf("expected default field to be resolved, got %s and %s", keyDefault, keyField)
+	}
+
+	if _, ok = filterKey(NewMatchQuery("x"), "_all"); ok {
+		t.Errorf("expected no key for match query")
+	}
+}
+
+func TestCachedFilterQueryWrappedReader(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath).WithFilterCache(1 << 20)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	doc := NewDocument("a").AddField(NewKeywordField("tag", "x"))
+	err = indexWriter.Update(doc.ID(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	// the cache is found through the readers wrapping the snapshot
+	wrapped := &profilingReader{
+		Reader: &globalStatsReader{
+			Reader: indexReader.reader,
+			stats:  newGlobalStats(),
+		},
+		profile: &SearchProfile{},
+	}
+	q := NewCachedFilterQuery(NewTermQuery("x").SetField("tag"))
+	options := searchOptionsFromConfig(config, SearchOptions{})
+	for i := 0; i < 2; i++ {
+		s, err := q.Searcher(wrapped, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := indexWriter.chill.FilterCacheStats()
+	if stats.TotFilterCacheMisses != 1 || stats.TotFilterCacheHits != 1 {
+		t.Errorf("expected 1 miss and 1 hit, got %d and %d",
+			stats.TotFilterCacheMisses, stats.TotFilterCacheHits)
+	}
+}
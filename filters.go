@@ -0,0 +1,246 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"sort"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// FiltersAggregation places each document in a bucket for every
+// query which matches it, and optionally in an other bucket
+// when none of them do
+type FiltersAggregation struct {
+	filters      map[string]Query
+	names        []string
+	other        string
+	hasOther     bool
+	options      *search.SearcherOptions
+	config       bool
+	aggregations map[string]search.Aggregation
+}
+
+// Filters builds a bucket for each of the named queries.  The queries are
+// evaluated as bitmaps of the documents they match in each index snapshot
+// searched, using the default search field and analyzer of the config of
+// the reader searched unless SearchConfig is used.
+func Filters(filters map[string]Query) *FiltersAggregation {
+	rv := &FiltersAggregation{
+		filters: filters,
+		aggregations: map[string]search.Aggregation{
+			"count": aggregations.CountMatches(),
+		},
+	}
+	for name := range filters {
+		rv.names = append(rv.names, name)
+	}
+	sort.Strings(rv.names)
+	return rv
+}
+
+// OtherBucket adds a bucket with the name for the documents
+// matching none of the queries
+func (a *FiltersAggregation) OtherBucket(name string) *FiltersAggregation {
+	a.other = name
+	a.hasOther = true
+	return a
+}
+
+// SearchConfig evaluates the queries with the default search field,
+// analyzer and similarity of the config
+func (a *FiltersAggregation) SearchConfig(config Config) *FiltersAggregation {
+	options := aggregationSearchOptions(config)
+	a.options = &options
+	a.config = true
+	return a
+}
+
+// WithSearcherOptions returns a copy of the aggregation evaluating the
+// queries with the options of the search, unless SearchConfig was used
+func (a *FiltersAggregation) WithSearcherOptions(options search.SearcherOptions) search.Aggregation {
+	if a.config {
+		return a
+	}
+	rv := *a
+	rv.options = &options
+	return &rv
+}
+
+func (a *FiltersAggregation) AddAggregation(name string, agg search.Aggregation) *FiltersAggregation {
+	a.aggregations[name] = agg
+	return a
+}
+
+func (a *FiltersAggregation) Fields() []string {
+	var rv []string
+	for _, agg := range a.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (a *FiltersAggregation) Calculator() search.Calculator {
+	rv := &FiltersCalculator{
+		agg:     a,
+		buckets: make(map[string]*search.Bucket, len(a.names)),
+	}
+	if a.options != nil {
+		rv.options = *a.options
+	} else {
+		rv.options = aggregationSearchOptions(DefaultConfig(""))
+	}
+	for _, name := range a.names {
+		rv.buckets[name] = search.NewBucket(name, a.aggregations)
+	}
+	if a.hasOther {
+		rv.other = search.NewBucket(a.other, a.aggregations)
+	}
+	return rv
+}
+
+// FiltersCalculator evaluates the queries again each time the documents
+// consumed come from a different reader
+type FiltersCalculator struct {
+	agg     *FiltersAggregation
+	options search.SearcherOptions
+	buckets map[string]*search.Bucket
+	other   *search.Bucket
+
+	reader   search.MatchReader
+	matchers []filterMatcher
+
+	list []*search.Bucket
+	err  error
+}
+
+func (f *FiltersCalculator) Consume(d *search.DocumentMatch) {
+	if f.err != nil {
+		return
+	}
+	if f.matchers == nil || d.Reader() != f.reader {
+		f.err = f.prepare(d.Reader())
+		if f.err != nil {
+			return
+		}
+	}
+	var matched bool
+	for i, name := range f.agg.names {
+		if f.matchers[i].Contains(d.Number) {
+			f.buckets[name].Consume(d)
+			matched = true
+		}
+	}
+	if !matched && f.other != nil {
+		f.other.Consume(d)
+	}
+}
+
+// prepare evaluates the queries for the reader as bitmaps, using the
+// index snapshot underlying the reader when there is one, or collecting
+// the matches of the searcher of each query otherwise
+func (f *FiltersCalculator) prepare(reader search.MatchReader) error {
+	f.reader = reader
+	f.matchers = make([]filterMatcher, 0, len(f.agg.names))
+	if snapshot := unwrapSnapshot(reader); snapshot != nil {
+		evaluator := &bitmapEvaluator{
+			ctx:      context.Background(),
+			snapshot: snapshot,
+			options:  f.options,
+		}
+		for _, name := range f.agg.names {
+			bitmaps, err := evaluator.queryBitmaps(f.agg.filters[name])
+			if err != nil {
+				return err
+			}
+			f.matchers = append(f.matchers, bitmaps)
+		}
+		return nil
+	}
+	searchReader, ok := reader.(search.Reader)
+	for _, name := range f.agg.names {
+		bitmap := roaring64.New()
+		if ok {
+			searcher, err := f.agg.filters[name].Searcher(searchReader, f.options)
+			if err != nil {
+				return err
+			}
+			err = visitMatches(context.Background(), searcher, func(_ *search.Context, dm *search.DocumentMatch) error {
+				bitmap.Add(dm.Number)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		f.matchers = append(f.matchers, bitmap)
+	}
+	return nil
+}
+
+func (f *FiltersCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FiltersCalculator); ok {
+		for name, bucket := range f.buckets {
+			if otherBucket, ok := other.buckets[name]; ok {
+				bucket.Merge(otherBucket)
+			}
+		}
+		if f.other != nil && other.other != nil {
+			f.other.Merge(other.other)
+		}
+		if f.err == nil {
+			f.err = other.err
+		}
+	}
+}
+
+func (f *FiltersCalculator) Finish() {
+	f.matchers = nil
+	f.reader = nil
+	for _, bucket := range f.buckets {
+		bucket.Finish()
+	}
+	if f.other != nil {
+		f.other.Finish()
+	}
+}
+
+// Buckets returns the bucket of each query ordered by name,
+// followed by the other bucket if there is one
+func (f *FiltersCalculator) Buckets() []*search.Bucket {
+	f.list = f.list[:0]
+	for _, name := range f.agg.names {
+		f.list = append(f.list, f.buckets[name])
+	}
+	if f.other != nil {
+		f.list = append(f.list, f.other)
+	}
+	return f.list
+}
+
+// Err returns the error evaluating a query, if any, which is returned
+// by the search, documents consumed after it are not placed in any bucket
+func (f *FiltersCalculator) Err() error {
+	return f.err
+}
+
+// filterMatcher holds the numbers of the documents a query matches
+type filterMatcher interface {
+	Contains(number uint64) bool
+}
@@ -0,0 +1,151 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring"
+	segment "github.com/strivewrt/bluge_segment_api"
+)
+
+// SegmentBitmaps is a set of live documents in a Snapshot, held as
+// one bitmap of segment local document numbers per segment
+type SegmentBitmaps struct {
+	snapshot *Snapshot
+	bitmaps  []*roaring.Bitmap
+}
+
+// EmptyBitmaps returns a set containing no documents
+func (i *Snapshot) EmptyBitmaps() *SegmentBitmaps {
+	rv := &SegmentBitmaps{
+		snapshot: i,
+		bitmaps:  make([]*roaring.Bitmap, len(i.segment)),
+	}
+	for segIndex := range rv.bitmaps {
+		rv.bitmaps[segIndex] = roaring.New()
+	}
+	return rv
+}
+
+// LiveBitmaps returns a set containing every live document
+func (i *Snapshot) LiveBitmaps() *SegmentBitmaps {
+	rv := &SegmentBitmaps{
+		snapshot: i,
+		bitmaps:  make([]*roaring.Bitmap, len(i.segment)),
+	}
+	for segIndex, seg := range i.segment {
+		rv.bitmaps[segIndex] = seg.DocNumbersLive()
+	}
+	return rv
+}
+
+// TermBitmaps returns the set of live documents containing the term in
+// the field.  The bitmaps are taken directly from the postings lists
+// where the segment supports it, otherwise the postings are iterated.
+func (i *Snapshot) TermBitmaps(term []byte, field string) (*SegmentBitmaps, error) {
+	itr, err := i.PostingsIterator(term, field, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = itr.Close()
+	}()
+
+	switch itr := itr.(type) {
+	case *postingsIteratorAll:
+		return i.LiveBitmaps(), nil
+	case *postingsIterator:
+		rv := &SegmentBitmaps{
+			snapshot: i,
+			bitmaps:  make([]*roaring.Bitmap, len(i.segment)),
+		}
+		for segIndex, segItr := range itr.iterators {
+			rv.bitmaps[segIndex], err = postingsBitmap(segItr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rv, nil
+	}
+	return nil, fmt.Errorf("unexpected postings iterator type %T", itr)
+}
+
+func postingsBitmap(itr segment.PostingsIterator) (*roaring.Bitmap, error) {
+	if oItr, ok := itr.(segment.OptimizablePostingsIterator); ok {
+		if docNum, ok := oItr.DocNum1Hit(); ok {
+			return roaring.BitmapOf(uint32(docNum)), nil
+		}
+		if bm := oItr.ActualBitmap(); bm != nil {
+			// the actual bitmap may be shared with the postings list
+			return bm.Clone(), nil
+		}
+		return roaring.New(), nil
+	}
+
+	rv := roaring.New()
+	next, err := itr.Next()
+	for err == nil && next != nil {
+		rv.Add(uint32(next.Number()))
+		next, err = itr.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Add adds the document with the specified global number to the set
+func (b *SegmentBitmaps) Add(number uint64) {
+	segIndex, localDocNum := b.snapshot.segmentIndexAndLocalDocNumFromGlobal(number)
+	b.bitmaps[segIndex].Add(uint32(localDocNum))
+}
+
+// And retains only the documents also in the other set
+func (b *SegmentBitmaps) And(other *SegmentBitmaps) {
+	for segIndex, bm := range b.bitmaps {
+		bm.And(other.bitmaps[segIndex])
+	}
+}
+
+// Or adds all of the documents in the other set
+func (b *SegmentBitmaps) Or(other *SegmentBitmaps) {
+	for segIndex, bm := range b.bitmaps {
+		bm.Or(other.bitmaps[segIndex])
+	}
+}
+
+// AndNot removes all of the documents in the other set
+func (b *SegmentBitmaps) AndNot(other *SegmentBitmaps) {
+	for segIndex, bm := range b.bitmaps {
+		bm.AndNot(other.bitmaps[segIndex])
+	}
+}
+
+// Count returns the number of documents in the set
+func (b *SegmentBitmaps) Count() uint64 {
+	var rv uint64
+	for _, bm := range b.bitmaps {
+		rv += bm.GetCardinality()
+	}
+	return rv
+}
+
+// Contains reports whether the document with the specified global number
+// is in the set
+func (b *SegmentBitmaps) Contains(number uint64) bool {
+	segIndex, localDocNum := b.snapshot.segmentIndexAndLocalDocNumFromGlobal(number)
+	return b.bitmaps[segIndex].Contains(uint32(localDocNum))
+}
@@ -51,6 +51,10 @@ type Config struct {
 	OptimizeConjunctionUnadorned bool
 	OptimizeDisjunctionUnadorned bool
 
+	// FilterCacheMaxBytes bounds the memory used to cache the documents
+	// of each segment matching a filter, 0 disables the filter cache
+	FilterCacheMaxBytes uint64
+
 	// MinSegmentsForInMemoryMerge represents the number of
 	// in-memory zap segments that persistSnapshotMaybeMerge() needs to
 	// see in an Snapshot before it decides to merge and persist
@@ -134,6 +138,11 @@ func (config Config) DisableOptimizeDisjunctionUnadorned() Config {
 	return config
 }
 
+func (config Config) WithFilterCache(maxBytes uint64) Config {
+	config.FilterCacheMaxBytes = maxBytes
+	return config
+}
+
 func (config Config) WithUnsafeBatches() Config {
 	config.UnsafeBatch = true
 	return config
@@ -0,0 +1,235 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/RoaringBitmap/roaring"
+	segment "github.com/strivewrt/bluge_segment_api"
+)
+
+// filterCache is a size bounded LRU cache of the documents in each segment
+// matching a filter, shared by all the snapshots of a Writer.  Segments are
+// immutable, so an entry remains valid until its segment is merged away.
+// Deletions only ever grow for a segment, so an entry computed with fewer
+// deletions can be reused after removing the current deletions.
+type filterCache struct {
+	m        sync.Mutex
+	maxBytes uint64
+	curBytes uint64
+	lru      *list.List // front is most recently used
+	entries  map[filterCacheKey]*list.Element
+	segments map[uint64][]filterCacheKey
+	stats    *Stats
+}
+
+type filterCacheKey struct {
+	segmentID uint64
+	filter    string
+}
+
+type filterCacheEntry struct {
+	key          filterCacheKey
+	bitmap       *roaring.Bitmap
+	deletedCount uint64
+	size         uint64
+}
+
+// filterCacheEntryOverhead approximates the bookkeeping memory of an entry
+const filterCacheEntryOverhead = 128
+
+func newFilterCache(maxBytes uint64, stats *Stats) *filterCache {
+	if maxBytes == 0 {
+		return nil
+	}
+	return &filterCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[filterCacheKey]*list.Element),
+		segments: make(map[uint64][]filterCacheKey),
+		stats:    stats,
+	}
+}
+
+func (c *filterCache) get(key filterCacheKey, deletedCount uint64) (*roaring.Bitmap, uint64, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.stats.TotFilterCacheMisses, 1)
+		return nil, 0, false
+	}
+	entry := elem.Value.(*filterCacheEntry)
+	if entry.deletedCount > deletedCount {
+		// computed for a newer snapshot than the one asking
+		atomic.AddUint64(&c.stats.TotFilterCacheMisses, 1)
+		return nil, 0, false
+	}
+	c.lru.MoveToFront(elem)
+	atomic.AddUint64(&c.stats.TotFilterCacheHits, 1)
+	return entry.bitmap, entry.deletedCount, true
+}
+
+func (c *filterCache) put(key filterCacheKey, bitmap *roaring.Bitmap, deletedCount uint64) {
+	entry := &filterCacheEntry{
+		key:          key,
+		bitmap:       bitmap,
+		deletedCount: deletedCount,
+		size:         bitmap.GetSizeInBytes() + uint64(len(key.filter)) + filterCacheEntryOverhead,
+	}
+	if entry.size > c.maxBytes {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		if elem.Value.(*filterCacheEntry).deletedCount > deletedCount {
+			// computed for a newer snapshot, which a stale one must not replace
+			return
+		}
+		c.removeElement(elem, true)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.segments[key.segmentID] = append(c.segments[key.segmentID], key)
+	c.curBytes += entry.size
+	for c.curBytes > c.maxBytes {
+		c.removeElement(c.lru.Back(), true)
+		atomic.AddUint64(&c.stats.TotFilterCacheEvictions, 1)
+	}
+	atomic.StoreUint64(&c.stats.CurFilterCacheBytes, c.curBytes)
+}
+
+// removeSegments drops the entries of segments which are no longer in use
+func (c *filterCache) removeSegments(segmentIDs []uint64) {
+	if c == nil || len(segmentIDs) == 0 {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	for _, segmentID := range segmentIDs {
+		for _, key := range c.segments[segmentID] {
+			if elem, ok := c.entries[key]; ok {
+				c.removeElement(elem, false)
+			}
+		}
+		delete(c.segments, segmentID)
+	}
+	atomic.StoreUint64(&c.stats.CurFilterCacheBytes, c.curBytes)
+}
+
+func (c *filterCache) removeElement(elem *list.Element, updateSegments bool) {
+	entry := elem.Value.(*filterCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.curBytes -= entry.size
+	if updateSegments {
+		keys := c.segments[entry.key.segmentID]
+		for i, key := range keys {
+			if key == entry.key {
+				keys = append(keys[:i], keys[i+1:]...)
+				break
+			}
+		}
+		if len(keys) == 0 {
+			delete(c.segments, entry.key.segmentID)
+		} else {
+			c.segments[entry.key.segmentID] = keys
+		}
+	}
+}
+
+// FilterBitmaps returns the documents matching the filter identified by the
+// key.  The bitmaps of segments missing from the filter cache are computed by
+// calling compute with a snapshot of just those segments.  The returned
+// bitmaps may be shared with the cache, and must not be modified.
+func (i *Snapshot) FilterBitmaps(key string,
+	compute func(*Snapshot) (*SegmentBitmaps, error)) (*SegmentBitmaps, error) {
+	cache := i.parent.filterCache
+	if cache == nil {
+		return compute(i)
+	}
+
+	rv := &SegmentBitmaps{
+		snapshot: i,
+		bitmaps:  make([]*roaring.Bitmap, len(i.segment)),
+	}
+	var missing []int
+	for segIndex, seg := range i.segment {
+		deletedCount := seg.deletedCount()
+		bm, cachedDeletedCount, ok := cache.get(filterCacheKey{segmentID: seg.id, filter: key}, deletedCount)
+		if !ok {
+			missing = append(missing, segIndex)
+			continue
+		}
+		if cachedDeletedCount < deletedCount {
+			bm = roaring.AndNot(bm, seg.deleted)
+		}
+		rv.bitmaps[segIndex] = bm
+	}
+	if len(missing) == 0 {
+		return rv, nil
+	}
+
+	partial := &Snapshot{
+		parent: i.parent,
+		epoch:  i.epoch,
+	}
+	var running uint64
+	for _, segIndex := range missing {
+		partial.segment = append(partial.segment, i.segment[segIndex])
+		partial.offsets = append(partial.offsets, running)
+		running += i.segment[segIndex].segment.Count()
+	}
+	computed, err := compute(partial)
+	if err != nil {
+		return nil, err
+	}
+	for partialIndex, segIndex := range missing {
+		seg := i.segment[segIndex]
+		bm := computed.bitmaps[partialIndex]
+		cache.put(filterCacheKey{segmentID: seg.id, filter: key}, bm, seg.deletedCount())
+		rv.bitmaps[segIndex] = bm
+	}
+	return rv, nil
+}
+
+// Clone returns a copy of the set which may be modified
+func (b *SegmentBitmaps) Clone() *SegmentBitmaps {
+	rv := &SegmentBitmaps{
+		snapshot: b.snapshot,
+		bitmaps:  make([]*roaring.Bitmap, len(b.bitmaps)),
+	}
+	for segIndex, bm := range b.bitmaps {
+		rv.bitmaps[segIndex] = bm.Clone()
+	}
+	return rv
+}
+
+// PostingsIterator returns an iterator over the documents in the set
+func (b *SegmentBitmaps) PostingsIterator() segment.PostingsIterator {
+	rv := b.snapshot.unadornedPostingsIterator(filterBitmapsTerm, filterBitmapsField)
+	for segIndex, bm := range b.bitmaps {
+		rv.iterators[segIndex] = newUnadornedPostingsIteratorFromBitmap(bm)
+	}
+	return rv
+}
+
+var filterBitmapsTerm = []byte("<filter>")
+
+const filterBitmapsField = "*"
@@ -0,0 +1,69 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+)
+
+func TestFilterCache(t *testing.T) {
+	var stats Stats
+	bm := roaring.BitmapOf(1, 2, 3)
+	entrySize := bm.GetSizeInBytes() + 1 + filterCacheEntryOverhead
+	c := newFilterCache(2*entrySize, &stats)
+
+	c.put(filterCacheKey{segmentID: 1, filter: "a"}, bm, 0)
+	c.put(filterCacheKey{segmentID: 1, filter: "b"}, bm, 0)
+	// touch a, so that b is the least recently used
+	if _, _, ok := c.get(filterCacheKey{segmentID: 1, filter: "a"}, 0); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.put(filterCacheKey{segmentID: 2, filter: "c"}, bm, 0)
+	if _, _, ok := c.get(filterCacheKey{segmentID: 1, filter: "b"}, 0); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	if stats.TotFilterCacheEvictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", stats.TotFilterCacheEvictions)
+	}
+
+	// entries computed with more deletions than the snapshot are not used
+	if _, _, ok := c.get(filterCacheKey{segmentID: 2, filter: "c"}, 0); !ok {
+		t.Errorf("expected c to be cached")
+	}
+	c.put(filterCacheKey{segmentID: 2, filter: "c"}, bm, 5)
+	if _, _, ok := c.get(filterCacheKey{segmentID: 2, filter: "c"}, 4); ok {
+		t.Errorf("expected entry with more deletions to miss")
+	}
+	// a stale snapshot does not replace the entry of a newer one
+	c.put(filterCacheKey{segmentID: 2, filter: "c"}, roaring.BitmapOf(1), 4)
+	if cached, deletedCount, ok := c.get(filterCacheKey{segmentID: 2, filter: "c"}, 5); !ok ||
+		deletedCount != 5 || cached.GetCardinality() != 3 {
+		t.Errorf("expected the entry with 5 deletions to be kept")
+	}
+
+	c.removeSegments([]uint64{1, 2})
+	if c.lru.Len() != 0 || len(c.entries) != 0 || len(c.segments) != 0 {
+		t.Errorf("expected cache to be empty")
+	}
+	if stats.CurFilterCacheBytes != 0 {
+		t.Errorf("expected 0 bytes, got %d", stats.CurFilterCacheBytes)
+	}
+
+	if newFilterCache(0, &stats) != nil {
+		t.Errorf("expected no cache without a size")
+	}
+}
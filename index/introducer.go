@@ -103,6 +103,7 @@ func (s *Writer) introduceSegment(next *segmentIntroduction, introduceSnapshotEp
 	// iterate through current segments
 	var running, docsToPersistCount uint64
 	var memSegments, fileSegments uint64
+	var removedSegmentIDs []uint64
 	for i := range root.segment {
 		// see if optimistic work included this segment
 		delta, ok := next.obsoletes[root.segment[i].id]
@@ -139,6 +140,8 @@ func (s *Writer) introduceSegment(next *segmentIntroduction, introduceSnapshotEp
 			root.segment[i].segment.AddRef()
 			newSnapshot.offsets = append(newSnapshot.offsets, running)
 			running += newss.segment.Count()
+		} else {
+			removedSegmentIDs = append(removedSegmentIDs, newss.id)
 		}
 
 		if !root.segment[i].segment.Persisted() {
@@ -172,6 +175,7 @@ func (s *Writer) introduceSegment(next *segmentIntroduction, introduceSnapshotEp
 	newSnapshot.updateSize()
 
 	s.replaceRoot(newSnapshot, next.persisted, next.persistedCallback)
+	s.filterCache.removeSegments(removedSegmentIDs)
 
 	close(next.applied)
 
@@ -256,6 +260,7 @@ func (s *Writer) introduceMerge(nextMerge *segmentMerge, introduceSnapshotEpoch
 	newSegmentDeleted := roaring.NewBitmap()
 	var running, docsToPersistCount uint64
 	var memSegments, fileSegments uint64
+	var removedSegmentIDs []uint64
 	for i := range root.segment {
 		segmentID := root.segment[i].id
 		segmentIsGoingAway := nextMerge.ProcessSegmentNow(segmentID, root.segment[i], newSegmentDeleted)
@@ -277,6 +282,8 @@ func (s *Writer) introduceMerge(nextMerge *segmentMerge, introduceSnapshotEpoch
 			} else {
 				fileSegments++
 			}
+		} else {
+			removedSegmentIDs = append(removedSegmentIDs, segmentID)
 		}
 	}
 
@@ -329,6 +336,7 @@ func (s *Writer) introduceMerge(nextMerge *segmentMerge, introduceSnapshotEpoch
 	newSnapshot.updateSize()
 
 	s.replaceRoot(newSnapshot, nil, nil)
+	s.filterCache.removeSegments(removedSegmentIDs)
 
 	// notify requester that we incorporated this
 	nextMerge.notifyCh <- &mergeTaskIntroStatus{snapshot: newSnapshot, skipped: skipped}
@@ -0,0 +1,70 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	segment "github.com/strivewrt/bluge_segment_api"
+)
+
+// DocumentNumbers resolves identifier terms to the numbers of the live
+// documents having them, by looking each term up in the dictionary of each
+// segment.  Terms without a live document are reported as not found.
+func (i *Snapshot) DocumentNumbers(ids []segment.Term) (numbers []uint64, found []bool, err error) {
+	numbers = make([]uint64, len(ids))
+	found = make([]bool, len(ids))
+	remaining := len(ids)
+
+	// newer segments are more likely to hold recently updated documents
+	for segIndex := len(i.segment) - 1; segIndex >= 0 && remaining > 0; segIndex-- {
+		seg := i.segment[segIndex]
+		dicts := make(map[string]segment.Dictionary)
+		var postings segment.PostingsList
+		var iterator segment.PostingsIterator
+		for idIndex, id := range ids {
+			if found[idIndex] {
+				continue
+			}
+			dict, ok := dicts[id.Field()]
+			if !ok {
+				dict, err = seg.segment.Dictionary(id.Field())
+				if err != nil {
+					return nil, nil, err
+				}
+				dicts[id.Field()] = dict
+			}
+			if dict == nil {
+				continue
+			}
+			postings, err = dict.PostingsList(id.Term(), seg.deleted, postings)
+			if err != nil {
+				return nil, nil, err
+			}
+			iterator, err = postings.Iterator(false, false, false, iterator)
+			if err != nil {
+				return nil, nil, err
+			}
+			posting, err := iterator.Next()
+			if err != nil {
+				return nil, nil, err
+			}
+			if posting != nil {
+				numbers[idIndex] = i.offsets[segIndex] + posting.Number()
+				found[idIndex] = true
+				remaining--
+			}
+		}
+	}
+	return numbers, found, nil
+}
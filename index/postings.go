@@ -117,6 +117,15 @@ func (i *postingsIterator) Advance(number uint64) (segment.Posting, error) {
 
 func (i *postingsIterator) Count() uint64 {
 	var rv uint64
+	if i.postings == nil {
+		// the unadorned iterators built by optimizations have no postings lists
+		for _, itr := range i.iterators {
+			if itr != nil {
+				rv += itr.Count()
+			}
+		}
+		return rv
+	}
 	for _, posting := range i.postings {
 		rv += posting.Count()
 	}
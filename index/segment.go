@@ -84,6 +84,13 @@ func (s *segmentSnapshot) Count() uint64 {
 	return rv
 }
 
+func (s *segmentSnapshot) deletedCount() uint64 {
+	if s.deleted == nil {
+		return 0
+	}
+	return s.deleted.GetCardinality()
+}
+
 // DocNumbersLive returns a bitmap containing doc numbers for all live docs
 func (s *segmentSnapshot) DocNumbersLive() *roaring.Bitmap {
 	rv := roaring.NewBitmap()
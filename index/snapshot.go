@@ -291,7 +291,22 @@ func (i *Snapshot) newPostingsIteratorAll(term string, results chan *asyncSegmen
 	return rv, nil
 }
 
+// StoredFieldTypesField is the hidden stored field recording the types of
+// the other stored fields of a document which opted in, each value is the
+// type as a single byte followed by the field name.  The name is not valid
+// UTF-8, so that it cannot clash with the name of a field.
+const StoredFieldTypesField = "\xff_types"
+
+// VisitStoredFields visits the stored fields of the document,
+// excluding the hidden field recording their types
 func (i *Snapshot) VisitStoredFields(number uint64, visitor segment.StoredFieldVisitor) error {
+	return i.VisitStoredFieldsWithTypes(number, visitor, nil)
+}
+
+// VisitStoredFieldsWithTypes visits the stored fields of the document,
+// along with the types recorded for them, if any
+func (i *Snapshot) VisitStoredFieldsWithTypes(number uint64, visitor segment.StoredFieldVisitor,
+	typeVisitor func(field string, typ byte)) error {
 	segmentIndex, localDocNum := i.segmentIndexAndLocalDocNumFromGlobal(number)
 
 	for _, vFields := range i.parent.config.virtualFields {
@@ -305,6 +320,12 @@ func (i *Snapshot) VisitStoredFields(number uint64, visitor segment.StoredFieldV
 		}
 	}
 	err := i.segment[segmentIndex].VisitDocument(localDocNum, func(name string, val []byte) bool {
+		if name == StoredFieldTypesField {
+			if typeVisitor != nil && len(val) > 1 {
+				typeVisitor(string(val[1:]), val[0])
+			}
+			return true
+		}
 		return visitor(name, val)
 	})
 	if err != nil {
@@ -32,6 +32,17 @@ func (s *Writer) Stats() Stats {
 	return s.stats
 }
 
+// FilterCacheStats returns the filter cache counters, read atomically
+// so that, unlike Stats, it is safe to use while the index is changing
+func (s *Writer) FilterCacheStats() Stats {
+	return Stats{
+		TotFilterCacheHits:      atomic.LoadUint64(&s.stats.TotFilterCacheHits),
+		TotFilterCacheMisses:    atomic.LoadUint64(&s.stats.TotFilterCacheMisses),
+		TotFilterCacheEvictions: atomic.LoadUint64(&s.stats.TotFilterCacheEvictions),
+		CurFilterCacheBytes:     atomic.LoadUint64(&s.stats.CurFilterCacheBytes),
+	}
+}
+
 // Stats tracks statistics about the index, fields that are
 // prefixed like CurXxxx are gauges (can go up and down),
 // and fields that are prefixed like TotXxxx are monotonically
@@ -138,6 +149,11 @@ type Stats struct {
 	TotEventFired    uint64
 	TotEventReturned uint64
 
+	TotFilterCacheHits      uint64
+	TotFilterCacheMisses    uint64
+	TotFilterCacheEvictions uint64
+	CurFilterCacheBytes     uint64
+
 	CurOnDiskBytes           uint64
 	CurOnDiskBytesUsedByRoot uint64 // FIXME not currently supported
 	CurOnDiskFiles           uint64
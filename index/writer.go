@@ -45,6 +45,8 @@ type Writer struct {
 
 	introductions chan *segmentIntroduction
 
+	filterCache *filterCache
+
 	rootPersisted      []chan error // closed when root is persisted
 	persistedCallbacks []func(error)
 
@@ -62,6 +64,7 @@ func OpenWriter(config Config) (*Writer, error) {
 		directory:      config.DirectoryFunc(),
 		closeCh:        make(chan struct{}),
 	}
+	rv.filterCache = newFilterCache(config.FilterCacheMaxBytes, &rv.stats)
 
 	// start the requested number of analysis workers
 	for i := 0; i < config.NumAnalysisWorkers; i++ {
@@ -413,6 +416,7 @@ func OpenReader(config Config) (*Snapshot, error) {
 		config:    config,
 		directory: config.DirectoryFunc(),
 	}
+	parent.filterCache = newFilterCache(config.FilterCacheMaxBytes, &parent.stats)
 
 	var err error
 	parent.segPlugin, err = loadSegmentPlugin(config.supportedSegmentPlugins,
@@ -706,6 +706,81 @@ func TestSearchTimeout(t *testing.T) {
 	}
 }
 
+func TestSearchTimeoutPartialResults(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := NewDocument("a").
+		AddField(NewTextField("desc", "water"))
+	err = indexWriter.Update(doc.ID(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatalf("error getting index reader: %v", err)
+	}
+
+	query := NewTermQuery("water").SetField("desc")
+	req := NewTopNSearch(10, query).
+		WithStandardAggregations().
+		SetTimeout(10 * time.Second)
+	dmi, err := indexReader.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dmi.(search.PartialDocumentMatchIterator).Partial() {
+		t.Errorf("expected complete results")
+	}
+	n, err := countHits(dmi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 hit, got %d", n)
+	}
+
+	// a slow query exhausts the timeout before the first match is collected
+	sq := &slowQuery{
+		actual: query,
+		delay:  50 * time.Millisecond, // on Windows timer resolution is 15ms
+	}
+	req = NewTopNSearch(10, sq).
+		WithStandardAggregations().
+		SetTimeout(1 * time.Microsecond)
+	dmi, err = indexReader.Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected partial results, got error: %v", err)
+	}
+	pdmi := dmi.(search.PartialDocumentMatchIterator)
+	if !pdmi.Partial() {
+		t.Errorf("expected partial results")
+	}
+	if pdmi.DocumentsExamined() != 0 {
+		t.Errorf("expected 0 documents examined, got %d", pdmi.DocumentsExamined())
+	}
+	if dmi.Aggregations().Count() != 0 {
+		t.Errorf("expected count 0, got %d", dmi.Aggregations().Count())
+	}
+
+	err = indexReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = indexWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestBatchRaceBug260(t *testing.T) {
 	tmpIndexPath := createTmpIndexPath(t)
 	defer cleanupTmpIndexPath(t, tmpIndexPath)
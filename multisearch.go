@@ -16,6 +16,8 @@ package bluge
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/blugelabs/bluge/search"
 )
@@ -73,23 +75,288 @@ func (m *MultiSearcherList) Close() (err error) {
 	return err
 }
 
+// MultiSearchOptions controls how a search is executed across several readers
+type MultiSearchOptions struct {
+	// GlobalStatistics first gathers term and collection statistics from all
+	// of the readers, and then scores matches in every reader using the merged
+	// statistics, so that a document scores the same regardless of which
+	// index it lives in.  This costs an additional pass building the searchers.
+	GlobalStatistics bool
+
+	// Parallel searches each reader concurrently with its own collector,
+	// and then merges the top matches and aggregations.  Only TopNSearch
+	// requests can be merged this way.
+	Parallel bool
+
+	// Tolerant returns the results from the readers which searched
+	// successfully, rather than failing when any one reader fails.
+	// The failures are reported by MultiSearchIterator.ReaderErrors.
+	// The search only fails if every reader fails.  Tolerant implies Parallel.
+	Tolerant bool
+
+	// Dedup keeps only the latest version of the documents sharing the
+	// same _id across readers, the others are excluded from the results,
+	// the counts and the aggregations.  The latest version is chosen among
+	// every copy of the _id, so a match is excluded when the latest version
+	// does not match.  By default the copy from the earliest reader wins,
+	// so readers should be passed in priority order.  This costs an
+	// additional pass over the matches, holding the _id of every match,
+	// and a lookup of each _id in every reader.
+	Dedup bool
+
+	// DedupVersionField names a sortable field holding a version or
+	// timestamp, when deduplicating the match with the greatest value
+	// wins, ties are won by the earliest reader
+	DedupVersionField string
+}
+
 func MultiSearch(ctx context.Context, req SearchRequest, readers ...*Reader) (search.DocumentMatchIterator, error) {
-	collector := req.Collector()
+	return MultiSearchWithOptions(ctx, req, MultiSearchOptions{}, readers...)
+}
+
+// MultiSearchWithOptions executes the search request across all of the readers,
+// the matches from every reader are ranked together using the request's sort order
+func MultiSearchWithOptions(ctx context.Context, req SearchRequest, options MultiSearchOptions,
+	readers ...*Reader) (search.DocumentMatchIterator, error) {
+	var stats *globalStats
+	if options.GlobalStatistics {
+		var err error
+		stats, err = gatherGlobalStats(req, options, readers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var drops []map[uint64]struct{}
+	if options.Dedup {
+		var err error
+		drops, err = gatherDuplicates(ctx, req, options, stats, readers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aggs := multiSearchAggregations(req, readers)
+	if options.Parallel || options.Tolerant {
+		dmItr, err := parallelMultiSearch(ctx, req, aggs, options, stats, drops, readers)
+		if err != nil {
+			return nil, err
+		}
+		return dmItr, nil
+	}
+
+	// the matches of every reader are aggregated together, so the
+	// aggregations building searchers use the config of the first
+	if len(readers) > 0 {
+		aggs = aggs.WithSearcherOptions(aggregationSearchOptions(readers[0].config))
+	}
 
+	collector := req.Collector()
 	var searchers []search.Searcher
-	for _, reader := range readers {
-		searcher, err := req.Searcher(reader.reader, reader.config)
+	for i, reader := range readers {
+		searcher, err := req.Searcher(multiSearchReader(reader, stats), reader.config)
 		if err != nil {
+			for _, searcher := range searchers {
+				_ = searcher.Close()
+			}
 			return nil, err
 		}
+		if drops != nil {
+			searcher = newDedupSearcher(searcher, drops[i])
+		}
 		searchers = append(searchers, searcher)
 	}
 
 	msl := NewMultiSearcherList(searchers)
-	dmItr, err := collector.Collect(ctx, req.Aggregations(), msl)
+	dmItr, err := collector.Collect(ctx, aggs, msl)
 	if err != nil {
 		return nil, err
 	}
 
+	if profile := multiSearchProfile(searchers); profile != nil {
+		dmItr = &profiledIterator{
+			DocumentMatchIterator: dmItr,
+			profile:               profile,
+			sum:                   true,
+		}
+	}
+
 	return dmItr, nil
 }
+
+// multiSearchAggregations passes every reader searched to the aggregations
+// which look up statistics in them, so that readers without matches count
+func multiSearchAggregations(req SearchRequest, readers []*Reader) search.Aggregations {
+	searchReaders := make([]search.Reader, len(readers))
+	for i, reader := range readers {
+		searchReaders[i] = reader.reader
+	}
+	return req.Aggregations().WithReaders(searchReaders)
+}
+
+func multiSearchReader(reader *Reader, stats *globalStats) search.Reader {
+	if stats != nil {
+		return &globalStatsReader{
+			Reader: reader.reader,
+			stats:  stats,
+		}
+	}
+	return reader.reader
+}
+
+// ReaderError reports the failure of one reader in a tolerant MultiSearch
+type ReaderError struct {
+	// Index is the position of the reader in the MultiSearch arguments
+	Index int
+	Err   error
+}
+
+func (e *ReaderError) Error() string {
+	return fmt.Sprintf("reader %d: %v", e.Index, e.Err)
+}
+
+func (e *ReaderError) Unwrap() error {
+	return e.Err
+}
+
+// MultiSearchIterator is returned by a parallel MultiSearch, it iterates
+// the merged matches, and reports any readers which failed
+type MultiSearchIterator struct {
+	results search.DocumentMatchCollection
+	bucket  *search.Bucket
+	index   int
+
+	partial  bool
+	examined int
+	errs     []*ReaderError
+	profile  *SearchProfile
+}
+
+func (i *MultiSearchIterator) Next() (*search.DocumentMatch, error) {
+	if i.index < len(i.results) {
+		rv := i.results[i.index]
+		i.index++
+		return rv, nil
+	}
+	return nil, nil
+}
+
+func (i *MultiSearchIterator) Aggregations() *search.Bucket {
+	return i.bucket
+}
+
+// Partial returns true if any reader stopped collecting before all
+// matches were examined, or if any reader failed
+func (i *MultiSearchIterator) Partial() bool {
+	return i.partial || len(i.errs) > 0
+}
+
+// DocumentsExamined returns the number of matches considered across all readers
+func (i *MultiSearchIterator) DocumentsExamined() int {
+	return i.examined
+}
+
+// Profile returns the profiles of the searches of each reader, as the
+// children of a combined profile, or nil if profiling was not enabled
+func (i *MultiSearchIterator) Profile() *SearchProfile {
+	return i.profile
+}
+
+// ReaderErrors returns the failures of individual readers,
+// in reader order, this is only non-empty in tolerant mode
+func (i *MultiSearchIterator) ReaderErrors() []*ReaderError {
+	return i.errs
+}
+
+type readerSearchResult struct {
+	searcher search.Searcher
+	dmi      search.DocumentMatchIterator
+	err      error
+}
+
+func parallelMultiSearch(ctx context.Context, req SearchRequest, aggs search.Aggregations, options MultiSearchOptions,
+	stats *globalStats, drops []map[uint64]struct{}, readers []*Reader) (*MultiSearchIterator, error) {
+	topN, ok := req.(*TopNSearch)
+	if !ok {
+		return nil, fmt.Errorf("parallel multisearch requires a TopNSearch, got %T", req)
+	}
+	collectors, merge := topN.readerCollectors(len(readers))
+
+	results := make([]readerSearchResult, len(readers))
+	var wg sync.WaitGroup
+	for i, reader := range readers {
+		wg.Add(1)
+		go func(i int, reader *Reader) {
+			defer wg.Done()
+			if err := stats.readerErr(i); err != nil {
+				results[i].err = err
+				return
+			}
+			searcher, err := req.Searcher(multiSearchReader(reader, stats), reader.config)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			if drops != nil {
+				searcher = newDedupSearcher(searcher, drops[i])
+			}
+			results[i].searcher = searcher
+			readerAggs := aggs.WithSearcherOptions(aggregationSearchOptions(reader.config))
+			results[i].dmi, results[i].err = collectors[i].Collect(ctx, readerAggs, searcher)
+		}(i, reader)
+	}
+	wg.Wait()
+
+	rv := &MultiSearchIterator{}
+	var matches search.DocumentMatchCollection
+	for i, result := range results {
+		if result.err != nil {
+			if !options.Tolerant {
+				return nil, &ReaderError{Index: i, Err: result.err}
+			}
+			rv.errs = append(rv.errs, &ReaderError{Index: i, Err: result.err})
+			continue
+		}
+
+		// offset hit numbers as if the readers were searched sequentially,
+		// so that ties are broken the same way as a sequential MultiSearch
+		hitOffset := rv.examined
+		if pdmi, ok := result.dmi.(search.PartialDocumentMatchIterator); ok {
+			rv.partial = rv.partial || pdmi.Partial()
+			rv.examined += pdmi.DocumentsExamined()
+		}
+		next, err := result.dmi.Next()
+		for err == nil && next != nil {
+			next.HitNumber += hitOffset
+			matches = append(matches, next)
+			next, err = result.dmi.Next()
+		}
+		if err != nil {
+			return nil, &ReaderError{Index: i, Err: err}
+		}
+
+		if rv.bucket == nil {
+			rv.bucket = result.dmi.Aggregations()
+		} else {
+			rv.bucket.Merge(result.dmi.Aggregations())
+		}
+	}
+
+	if len(readers) > 0 && len(rv.errs) == len(readers) {
+		return nil, rv.errs[0]
+	}
+	if rv.bucket == nil {
+		rv.bucket = search.NewBucket("", aggs)
+		rv.bucket.Finish()
+	}
+	rv.results = merge(matches)
+
+	searchers := make([]search.Searcher, len(results))
+	for i, result := range results {
+		if result.err == nil {
+			searchers[i] = result.searcher
+		}
+	}
+	rv.profile = multiSearchProfile(searchers)
+	return rv, nil
+}
@@ -0,0 +1,227 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"bytes"
+	"context"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/collector"
+)
+
+// dedupCandidate is a copy of a document with an _id
+type dedupCandidate struct {
+	reader  int
+	number  uint64
+	version []byte
+}
+
+// gatherDuplicates runs the search on each reader only to find the _id of
+// every match, then looks each _id up in every reader, whether or not that
+// copy matches, to choose the winning copy.  For each reader it returns the
+// document numbers of the matches which lost to a copy in another reader,
+// so a match is dropped even when the copy which won does not match.
+func gatherDuplicates(ctx context.Context, req SearchRequest, options MultiSearchOptions,
+	stats *globalStats, readers []*Reader) ([]map[uint64]struct{}, error) {
+	fields := []string{_idField}
+	var versionFields []string
+	var versionSource search.TextValueSource
+	if options.DedupVersionField != "" {
+		fields = append(fields, options.DedupVersionField)
+		versionFields = []string{options.DedupVersionField}
+		versionSource = search.Field(options.DedupVersionField)
+	}
+
+	rv := make([]map[uint64]struct{}, len(readers))
+	matched := make([]map[string]dedupCandidate, len(readers))
+	var ids []segment.Term
+	seen := make(map[string]struct{})
+	for i, reader := range readers {
+		rv[i] = make(map[uint64]struct{})
+		if stats.readerErr(i) != nil {
+			// the reader is not searched, and is reported by the search
+			continue
+		}
+		candidates, err := dedupCandidates(ctx, req, stats, reader, fields, versionSource)
+		if err != nil {
+			if options.Tolerant {
+				// the reader fails again in the search itself, and is reported there
+				continue
+			}
+			return nil, err
+		}
+		matched[i] = candidates
+		for id := range candidates {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				ids = append(ids, Identifier(id))
+			}
+		}
+	}
+
+	winners := make(map[string]dedupCandidate, len(ids))
+	for i, reader := range readers {
+		if matched[i] == nil {
+			continue
+		}
+		copies, err := reader.MultiGet(ids, versionFields...)
+		if err != nil {
+			if options.Tolerant {
+				continue
+			}
+			return nil, err
+		}
+		for idIndex, match := range copies {
+			if match == nil {
+				continue
+			}
+			candidate := dedupCandidate{
+				reader: i,
+				number: match.Number,
+			}
+			if versionSource != nil {
+				candidate.version = append([]byte(nil), versionSource.Value(match)...)
+			}
+			id := string(ids[idIndex].Term())
+			prev, ok := winners[id]
+			// ties keep the copy from the earlier reader
+			if !ok || bytes.Compare(candidate.version, prev.version) > 0 {
+				winners[id] = candidate
+			}
+		}
+	}
+
+	for i, candidates := range matched {
+		for id, candidate := range candidates {
+			if winner, ok := winners[id]; ok && winner.reader != i {
+				rv[i][candidate.number] = struct{}{}
+			}
+		}
+	}
+	return rv, nil
+}
+
+// dedupCandidates returns the matches from one reader keyed by _id
+func dedupCandidates(ctx context.Context, req SearchRequest, stats *globalStats, reader *Reader,
+	fields []string, versionSource search.TextValueSource) (map[string]dedupCandidate, error) {
+	searcher, err := req.Searcher(multiSearchReader(reader, stats), reader.config)
+	if err != nil {
+		return nil, err
+	}
+	rv := make(map[string]dedupCandidate)
+	err = visitMatches(ctx, searcher, func(sctx *search.Context, dm *search.DocumentMatch) error {
+		err := dm.LoadDocumentValues(sctx, fields)
+		if err != nil {
+			return err
+		}
+		ids := dm.DocValues(_idField)
+		if len(ids) == 0 {
+			return nil
+		}
+		if _, ok := rv[string(ids[0])]; ok {
+			return nil
+		}
+		candidate := dedupCandidate{
+			number: dm.Number,
+		}
+		if versionSource != nil {
+			candidate.version = append([]byte(nil), versionSource.Value(dm)...)
+		}
+		rv[string(ids[0])] = candidate
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// visitMatches invokes the callback for every match from the searcher,
+// recycling each match afterwards, and closes the searcher
+func visitMatches(ctx context.Context, searcher search.Searcher,
+	callback func(*search.Context, *search.DocumentMatch) error) error {
+	defer func() {
+		_ = searcher.Close()
+	}()
+	sctx := search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	var hitNumber int
+	for {
+		if hitNumber%collector.CheckDoneEvery == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+		next, err := searcher.Next(sctx)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		hitNumber++
+		err = callback(sctx, next)
+		if err != nil {
+			return err
+		}
+		sctx.DocumentMatchPool.Put(next)
+	}
+}
+
+// dedupSearcher skips the matches which lost deduplication
+type dedupSearcher struct {
+	search.Searcher
+	drop map[uint64]struct{}
+}
+
+func (s *dedupSearcher) Unwrap() search.Searcher {
+	return s.Searcher
+}
+
+func newDedupSearcher(searcher search.Searcher, drop map[uint64]struct{}) search.Searcher {
+	if len(drop) == 0 {
+		return searcher
+	}
+	return &dedupSearcher{
+		Searcher: searcher,
+		drop:     drop,
+	}
+}
+
+func (s *dedupSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	next, err := s.Searcher.Next(ctx)
+	return s.skipDropped(ctx, next, err)
+}
+
+func (s *dedupSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	next, err := s.Searcher.Advance(ctx, number)
+	return s.skipDropped(ctx, next, err)
+}
+
+func (s *dedupSearcher) skipDropped(ctx *search.Context, next *search.DocumentMatch, err error) (
+	*search.DocumentMatch, error) {
+	for err == nil && next != nil {
+		if _, dropped := s.drop[next.Number]; !dropped {
+			return next, nil
+		}
+		ctx.DocumentMatchPool.Put(next)
+		next, err = s.Searcher.Next(ctx)
+	}
+	return next, err
+}
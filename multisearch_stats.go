@@ -0,0 +1,211 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// globalStats holds term and collection statistics merged across readers
+type globalStats struct {
+	collection map[string]*globalCollectionStats
+	docFreq    map[string]map[string]uint64
+
+	// readerErrs holds the errors of the readers skipped in tolerant mode
+	readerErrs map[int]error
+}
+
+func newGlobalStats() *globalStats {
+	return &globalStats{
+		collection: make(map[string]*globalCollectionStats),
+		docFreq:    make(map[string]map[string]uint64),
+		readerErrs: make(map[int]error),
+	}
+}
+
+// readerErr returns the error gathering the statistics of the reader,
+// the reader is not searched when there is one
+func (g *globalStats) readerErr(i int) error {
+	if g == nil {
+		return nil
+	}
+	return g.readerErrs[i]
+}
+
+func (g *globalStats) merge(other *globalStats) {
+	for field, terms := range other.docFreq {
+		for term, docFreq := range terms {
+			g.addDocFreq(field, []byte(term), docFreq)
+		}
+	}
+}
+
+func (g *globalStats) addDocFreq(field string, term []byte, docFreq uint64) {
+	terms, ok := g.docFreq[field]
+	if !ok {
+		terms = make(map[string]uint64)
+		g.docFreq[field] = terms
+	}
+	terms[string(term)] += docFreq
+}
+
+// gatherGlobalStats builds the searcher for the request on each reader,
+// summing the document frequency of each term as its postings are opened,
+// then sums the collection statistics of the fields used across readers.
+// A reader which does not open the postings of a term, such as when it is
+// not in its dictionary for a multi-term query, adds nothing for it.
+// In tolerant mode a reader which fails adds nothing, and its error is
+// reported by the search in place of its matches.
+func gatherGlobalStats(req SearchRequest, options MultiSearchOptions, readers []*Reader) (*globalStats, error) {
+	rv := newGlobalStats()
+	readerStats := make([]*globalStats, len(readers))
+	for i, reader := range readers {
+		readerStats[i] = newGlobalStats()
+		err := recordTerms(req, reader, readerStats[i])
+		if err != nil {
+			if options.Tolerant {
+				rv.readerErrs[i] = err
+				continue
+			}
+			return nil, err
+		}
+		for field := range readerStats[i].docFreq {
+			rv.collection[field] = &globalCollectionStats{}
+		}
+	}
+
+	for i, reader := range readers {
+		if rv.readerErrs[i] != nil {
+			continue
+		}
+		for field := range rv.collection {
+			collStats, err := reader.reader.CollectionStats(field)
+			if err != nil {
+				if options.Tolerant {
+					rv.readerErrs[i] = err
+					break
+				}
+				return nil, err
+			}
+			if collStats != nil {
+				readerStats[i].collection[field] = &globalCollectionStats{}
+				readerStats[i].collection[field].Merge(collStats)
+			}
+		}
+	}
+
+	for i, stats := range readerStats {
+		if rv.readerErrs[i] != nil {
+			continue
+		}
+		rv.merge(stats)
+		for field, collStats := range stats.collection {
+			rv.collection[field].Merge(collStats)
+		}
+	}
+	return rv, nil
+}
+
+// recordTerms adds the document frequency of the terms of the searcher
+// for the request on the reader to the stats
+func recordTerms(req SearchRequest, reader *Reader, stats *globalStats) error {
+	recorder := &termRecordingReader{
+		Reader: reader.reader,
+		stats:  stats,
+		seen:   make(map[string]map[string]struct{}),
+	}
+	searcher, err := req.Searcher(recorder, reader.config)
+	if err != nil {
+		return err
+	}
+	return searcher.Close()
+}
+
+// termRecordingReader adds the document frequency of every term whose
+// postings are opened while building a searcher, once per reader
+type termRecordingReader struct {
+	search.Reader
+	stats *globalStats
+	seen  map[string]map[string]struct{}
+}
+
+func (r *termRecordingReader) PostingsIterator(term []byte, field string, includeFreq, includeNorm,
+	includeTermVectors bool) (segment.PostingsIterator, error) {
+	rv, err := r.Reader.PostingsIterator(term, field, includeFreq, includeNorm, includeTermVectors)
+	if err != nil {
+		return nil, err
+	}
+	terms, ok := r.seen[field]
+	if !ok {
+		terms = make(map[string]struct{})
+		r.seen[field] = terms
+	}
+	if _, ok := terms[string(term)]; !ok {
+		terms[string(term)] = struct{}{}
+		r.stats.addDocFreq(field, term, rv.Count())
+	}
+	return rv, nil
+}
+
+// globalStatsReader scores using statistics merged across readers
+type globalStatsReader struct {
+	search.Reader
+	stats *globalStats
+}
+
+func (r *globalStatsReader) Unwrap() search.Reader {
+	return r.Reader
+}
+
+func (r *globalStatsReader) CollectionStats(field string) (segment.CollectionStats, error) {
+	if collStats, ok := r.stats.collection[field]; ok {
+		return collStats, nil
+	}
+	return r.Reader.CollectionStats(field)
+}
+
+func (r *globalStatsReader) DocumentFrequency(field string, term []byte) (uint64, bool) {
+	if terms, ok := r.stats.docFreq[field]; ok {
+		docFreq, ok := terms[string(term)]
+		return docFreq, ok
+	}
+	return 0, false
+}
+
+type globalCollectionStats struct {
+	totalDocCount    uint64
+	docCount         uint64
+	sumTotalTermFreq uint64
+}
+
+func (c *globalCollectionStats) TotalDocumentCount() uint64 {
+	return c.totalDocCount
+}
+
+func (c *globalCollectionStats) DocumentCount() uint64 {
+	return c.docCount
+}
+
+func (c *globalCollectionStats) SumTotalTermFrequency() uint64 {
+	return c.sumTotalTermFreq
+}
+
+func (c *globalCollectionStats) Merge(other segment.CollectionStats) {
+	c.totalDocCount += other.TotalDocumentCount()
+	c.docCount += other.DocumentCount()
+	c.sumTotalTermFreq += other.SumTotalTermFrequency()
+}
@@ -16,7 +16,12 @@ package bluge
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/blugelabs/bluge/search"
 )
 
 func TestMultiSearch(t *testing.T) {
@@ -102,3 +107,402 @@ func TestMultiSearch(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func openTestWriterWithDocs(t *testing.T, path string, docs ...*Document) *Writer {
+	writer, err := OpenWriter(DefaultConfig(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := NewBatch()
+	for _, doc := range docs {
+		batch.Update(doc.ID(), doc)
+	}
+	err = writer.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writer
+}
+
+func TestMultiSearchGlobalStatistics(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+
+	// the term "rare" is common in the first index, and rare in the second
+	var docs1 []*Document
+	for i := 0; i < 10; i++ {
+		docs1 = append(docs1, NewDocument(fmt.Sprintf("a%d", i)).
+			AddField(NewTextField("desc", "rare words")))
+	}
+	var docs2 []*Document
+	docs2 = append(docs2, NewDocument("b0").
+		AddField(NewTextField("desc", "rare words")))
+	for i := 1; i < 10; i++ {
+		docs2 = append(docs2, NewDocument(fmt.Sprintf("b%d", i)).
+			AddField(NewTextField("desc", "other words")))
+	}
+
+	writer1 := openTestWriterWithDocs(t, tmpIndexPath, docs1...)
+	defer func() { _ = writer1.Close() }()
+	writer2 := openTestWriterWithDocs(t, tmpIndexPath2, docs2...)
+	defer func() { _ = writer2.Close() }()
+
+	reader1, err := writer1.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader1.Close() }()
+	reader2, err := writer2.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader2.Close() }()
+
+	scores := func(options MultiSearchOptions) map[float64]int {
+		req := NewTopNSearch(100, NewTermQuery("rare").SetField("desc"))
+		dmi, err := MultiSearchWithOptions(context.Background(), req, options, reader1, reader2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv := map[float64]int{}
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			rv[next.Score]++
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rv
+	}
+
+	// identical documents score differently based on the index they're in
+	local := scores(MultiSearchOptions{})
+	if len(local) != 2 {
+		t.Errorf("expected 2 distinct scores with local statistics, got %v", local)
+	}
+
+	// with global statistics, they score the same
+	global := scores(MultiSearchOptions{GlobalStatistics: true})
+	if len(global) != 1 {
+		t.Errorf("expected 1 distinct score with global statistics, got %v", global)
+	}
+	for _, count := range global {
+		if count != 11 {
+			t.Errorf("expected 11 hits, got %d", count)
+		}
+	}
+
+	// a term opened twice by the same reader is counted once
+	rare := NewTermQuery("rare").SetField("desc")
+	stats, err := gatherGlobalStats(NewTopNSearch(10, NewBooleanQuery().AddShould(rare, rare)), MultiSearchOptions{},
+		[]*Reader{reader1, reader2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.docFreq["desc"]["rare"] != 11 {
+		t.Errorf("expected global document frequency 11, got %d", stats.docFreq["desc"]["rare"])
+	}
+	if stats.collection["desc"].TotalDocumentCount() != 20 {
+		t.Errorf("expected 20 documents, got %d", stats.collection["desc"].TotalDocumentCount())
+	}
+}
+
+func TestMultiSearchParallel(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+
+	var docs1, docs2 []*Document
+	for i := 0; i < 10; i++ {
+		docs1 = append(docs1, NewDocument(fmt.Sprintf("doc-%02d", i*2)).
+			AddField(NewKeywordField("name", "x")))
+		docs2 = append(docs2, NewDocument(fmt.Sprintf("doc-%02d", i*2+1)).
+			AddField(NewKeywordField("name", "x")))
+	}
+
+	writer1 := openTestWriterWithDocs(t, tmpIndexPath, docs1...)
+	defer func() { _ = writer1.Close() }()
+	writer2 := openTestWriterWithDocs(t, tmpIndexPath2, docs2...)
+	defer func() { _ = writer2.Close() }()
+
+	reader1, err := writer1.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader1.Close() }()
+	reader2, err := writer2.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader2.Close() }()
+
+	run := func(req *TopNSearch, options MultiSearchOptions) (ids []string, count uint64) {
+		dmi, err := MultiSearchWithOptions(context.Background(), req, options, reader1, reader2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			ids = append(ids, string(next.SortValue[0]))
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids, dmi.Aggregations().Count()
+	}
+
+	requests := map[string]func() *TopNSearch{
+		"from": func() *TopNSearch {
+			return NewTopNSearch(5, NewMatchAllQuery()).SetFrom(3).SortBy([]string{"_id"}).
+				WithStandardAggregations()
+		},
+		"desc": func() *TopNSearch {
+			return NewTopNSearch(4, NewMatchAllQuery()).SortBy([]string{"-_id"}).
+				WithStandardAggregations()
+		},
+		"after": func() *TopNSearch {
+			return NewTopNSearch(4, NewMatchAllQuery()).SortBy([]string{"_id"}).
+				After([][]byte{[]byte("doc-07")}).WithStandardAggregations()
+		},
+		"before": func() *TopNSearch {
+			return NewTopNSearch(4, NewMatchAllQuery()).SortBy([]string{"_id"}).
+				Before([][]byte{[]byte("doc-07")}).WithStandardAggregations()
+		},
+	}
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			expectIDs, expectCount := run(req(), MultiSearchOptions{})
+			gotIDs, gotCount := run(req(), MultiSearchOptions{Parallel: true})
+			if !reflect.DeepEqual(expectIDs, gotIDs) {
+				t.Errorf("expected %v, got %v", expectIDs, gotIDs)
+			}
+			if expectCount != 20 || gotCount != expectCount {
+				t.Errorf("expected count %d, got %d", expectCount, gotCount)
+			}
+		})
+	}
+}
+
+// failingQuery fails to build a searcher for one specific reader,
+// or only when gathering its statistics if failStats is set
+type failingQuery struct {
+	Query
+	fail      search.Reader
+	failStats bool
+}
+
+var errTestReaderFailed = errors.New("reader failed")
+
+func (q *failingQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	if recorder, ok := i.(*termRecordingReader); ok && q.failStats && recorder.Reader == q.fail {
+		return nil, errTestReaderFailed
+	}
+	if i == q.fail && !q.failStats {
+		return nil, errTestReaderFailed
+	}
+	return q.Query.Searcher(i, options)
+}
+
+func TestMultiSearchTolerant(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+
+	writer1 := openTestWriterWithDocs(t, tmpIndexPath,
+		NewDocument("a"), NewDocument("b"))
+	defer func() { _ = writer1.Close() }()
+	writer2 := openTestWriterWithDocs(t, tmpIndexPath2,
+		NewDocument("c"))
+	defer func() { _ = writer2.Close() }()
+
+	reader1, err := writer1.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader1.Close() }()
+	reader2, err := writer2.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader2.Close() }()
+
+	q := &failingQuery{Query: NewMatchAllQuery(), fail: reader2.reader}
+
+	_, err = MultiSearchWithOptions(context.Background(), NewTopNSearch(10, q),
+		MultiSearchOptions{Parallel: true}, reader1, reader2)
+	if !errors.Is(err, errTestReaderFailed) {
+		t.Fatalf("expected reader failure, got %v", err)
+	}
+
+	dmi, err := MultiSearchWithOptions(context.Background(), NewTopNSearch(10, q).WithStandardAggregations(),
+		MultiSearchOptions{Tolerant: true}, reader1, reader2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, err := countHits(dmi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits != 2 {
+		t.Errorf("expected 2 hits from the healthy reader, got %d", hits)
+	}
+	if dmi.Aggregations().Count() != 2 {
+		t.Errorf("expected count 2, got %d", dmi.Aggregations().Count())
+	}
+	msi := dmi.(*MultiSearchIterator)
+	if !msi.Partial() {
+		t.Errorf("expected results to be flagged partial")
+	}
+	readerErrs := msi.ReaderErrors()
+	if len(readerErrs) != 1 || readerErrs[0].Index != 1 || !errors.Is(readerErrs[0], errTestReaderFailed) {
+		t.Errorf("expected failure of reader 1, got %v", readerErrs)
+	}
+
+	// a reader failing to gather statistics is skipped as well
+	statsQuery := &failingQuery{Query: NewMatchAllQuery(), fail: reader2.reader, failStats: true}
+	dmi, err = MultiSearchWithOptions(context.Background(), NewTopNSearch(10, statsQuery),
+		MultiSearchOptions{Tolerant: true, GlobalStatistics: true}, reader1, reader2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, err = countHits(dmi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits != 2 {
+		t.Errorf("expected 2 hits from the healthy reader, got %d", hits)
+	}
+	readerErrs = dmi.(*MultiSearchIterator).ReaderErrors()
+	if len(readerErrs) != 1 || readerErrs[0].Index != 1 || !errors.Is(readerErrs[0], errTestReaderFailed) {
+		t.Errorf("expected failure of reader 1, got %v", readerErrs)
+	}
+
+	// when every reader fails, so does the search
+	q.fail = reader1.reader
+	_, err = MultiSearchWithOptions(context.Background(), NewTopNSearch(10, q),
+		MultiSearchOptions{Tolerant: true}, reader1)
+	if !errors.Is(err, errTestReaderFailed) {
+		t.Errorf("expected reader failure, got %v", err)
+	}
+}
+
+func TestMultiSearchDedup(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+
+	newDoc := func(id, src string, version float64) *Document {
+		return NewDocument(id).
+			AddField(NewKeywordField("src", src).Sortable()).
+			AddField(NewNumericField("version", version).Sortable())
+	}
+
+	// "a" was updated after the older index rolled over
+	writer1 := openTestWriterWithDocs(t, tmpIndexPath,
+		newDoc("a", "old", 1), newDoc("b", "old", 1))
+	defer func() { _ = writer1.Close() }()
+	writer2 := openTestWriterWithDocs(t, tmpIndexPath2,
+		newDoc("a", "new", 2), newDoc("c", "new", 1))
+	defer func() { _ = writer2.Close() }()
+
+	reader1, err := writer1.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader1.Close() }()
+	reader2, err := writer2.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader2.Close() }()
+
+	run := func(q Query, options MultiSearchOptions) (hits map[string]string, count uint64) {
+		req := NewTopNSearch(10, q).
+			SortBy([]string{"_id", "src"}).
+			WithStandardAggregations()
+		dmi, err := MultiSearchWithOptions(context.Background(), req, options, reader1, reader2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits = map[string]string{}
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			hits[string(next.SortValue[0])] += string(next.SortValue[1])
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hits, dmi.Aggregations().Count()
+	}
+
+	tests := []struct {
+		name   string
+		query  Query
+		option MultiSearchOptions
+		expect map[string]string
+		count  uint64
+	}{
+		{
+			name:   "none",
+			option: MultiSearchOptions{},
+			expect: map[string]string{"a": "newold", "b": "old", "c": "new"},
+			count:  4,
+		},
+		{
+			name:   "priority",
+			option: MultiSearchOptions{Dedup: true},
+			expect: map[string]string{"a": "old", "b": "old", "c": "new"},
+			count:  3,
+		},
+		{
+			name:   "version",
+			option: MultiSearchOptions{Dedup: true, DedupVersionField: "version"},
+			expect: map[string]string{"a": "new", "b": "old", "c": "new"},
+			count:  3,
+		},
+		{
+			name:   "version parallel",
+			option: MultiSearchOptions{Dedup: true, DedupVersionField: "version", Parallel: true},
+			expect: map[string]string{"a": "new", "b": "old", "c": "new"},
+			count:  3,
+		},
+		{
+			// the newer copy of "a" does not match, so the older one is stale
+			name:   "version winner not matching",
+			query:  NewTermQuery("old").SetField("src"),
+			option: MultiSearchOptions{Dedup: true, DedupVersionField: "version"},
+			expect: map[string]string{"b": "old"},
+			count:  1,
+		},
+		{
+			name:   "priority winner not matching",
+			query:  NewTermQuery("new").SetField("src"),
+			option: MultiSearchOptions{Dedup: true},
+			expect: map[string]string{"c": "new"},
+			count:  1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := test.query
+			if q == nil {
+				q = NewMatchAllQuery()
+			}
+			hits, count := run(q, test.option)
+			if !reflect.DeepEqual(hits, test.expect) {
+				t.Errorf("expected %v, got %v", test.expect, hits)
+			}
+			if count != test.count {
+				t.Errorf("expected count %d, got %d", test.count, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// ErrPointInTimeNotFound is returned when a point-in-time is unknown,
+// either because it was never opened, or because its lease expired
+var ErrPointInTimeNotFound = errors.New("point-in-time not found or expired")
+
+// PointInTimeRegistry keeps Readers open under an opaque ID, so that
+// several requests can page through the same unchanging view of an index.
+// Each point-in-time holds a keep-alive lease, once the lease expires
+// without being renewed the Reader is closed automatically.
+type PointInTimeRegistry struct {
+	m    sync.Mutex
+	pits map[string]*PointInTime
+}
+
+func NewPointInTimeRegistry() *PointInTimeRegistry {
+	return &PointInTimeRegistry{
+		pits: make(map[string]*PointInTime),
+	}
+}
+
+// Open registers the Reader as a new point-in-time.  The registry takes
+// ownership of the Reader, it will be closed when the point-in-time
+// is closed or the keep-alive lease expires.
+func (r *PointInTimeRegistry) Open(reader *Reader, keepAlive time.Duration) (*PointInTime, error) {
+	id, err := newPointInTimeID()
+	if err != nil {
+		return nil, err
+	}
+	rv := &PointInTime{
+		id:        id,
+		reader:    reader,
+		registry:  r,
+		keepAlive: keepAlive,
+	}
+
+	r.m.Lock()
+	r.pits[id] = rv
+	rv.expires = time.Now().Add(keepAlive)
+	rv.timer = time.AfterFunc(keepAlive, rv.leaseExpired)
+	r.m.Unlock()
+
+	return rv, nil
+}
+
+// Get returns the point-in-time with the specified ID, renewing its lease
+// for the specified keep-alive duration.  A keep-alive of 0 renews the
+// lease using the duration it was opened with.
+func (r *PointInTimeRegistry) Get(id string, keepAlive time.Duration) (*PointInTime, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	rv, ok := r.pits[id]
+	if !ok {
+		return nil, ErrPointInTimeNotFound
+	}
+	if keepAlive > 0 {
+		rv.keepAlive = keepAlive
+	}
+	rv.expires = time.Now().Add(rv.keepAlive)
+	rv.timer.Reset(rv.keepAlive)
+	return rv, nil
+}
+
+// Resume parses a cursor token produced by PointInTime.Cursor, and returns
+// the point-in-time it refers to, along with the decoded cursor
+func (r *PointInTimeRegistry) Resume(token string, keepAlive time.Duration) (*PointInTime, *Cursor, error) {
+	cursor, err := ParseCursor(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	pit, err := r.Get(cursor.PointInTimeID, keepAlive)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pit, cursor, nil
+}
+
+// Len returns the number of open points-in-time
+func (r *PointInTimeRegistry) Len() int {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return len(r.pits)
+}
+
+// Close releases the point-in-time with the specified ID
+func (r *PointInTimeRegistry) Close(id string) error {
+	r.m.Lock()
+	pit, ok := r.pits[id]
+	r.m.Unlock()
+	if !ok {
+		return ErrPointInTimeNotFound
+	}
+	return pit.Close()
+}
+
+// CloseAll releases every open point-in-time
+func (r *PointInTimeRegistry) CloseAll() (err error) {
+	r.m.Lock()
+	pits := make([]*PointInTime, 0, len(r.pits))
+	for _, pit := range r.pits {
+		pits = append(pits, pit)
+	}
+	r.m.Unlock()
+	for _, pit := range pits {
+		cerr := pit.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// PointInTime is a Reader pinned in a PointInTimeRegistry
+type PointInTime struct {
+	id        string
+	reader    *Reader
+	registry  *PointInTimeRegistry
+	keepAlive time.Duration
+	timer     *time.Timer
+
+	// the following are protected by the registry lock
+	expires time.Time
+	active  int
+	closed  bool
+}
+
+// ID returns the opaque identifier of this point-in-time
+func (p *PointInTime) ID() string {
+	return p.id
+}
+
+// Search executes the search request against the pinned Reader.
+// The Reader is kept open until the returned iterator is exhausted
+// or closed, even if the lease expires in the meantime.
+func (p *PointInTime) Search(ctx context.Context, req SearchRequest) (*PointInTimeIterator, error) {
+	if !p.acquire() {
+		return nil, ErrPointInTimeNotFound
+	}
+	dmi, err := p.reader.Search(ctx, req)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return &PointInTimeIterator{
+		pit: p,
+		dmi: dmi,
+	}, nil
+}
+
+// SearchAfter executes the search request against the pinned Reader,
+// resuming after the position of the cursor, if it is not nil.  The
+// cursor must have been built for this point-in-time.
+func (p *PointInTime) SearchAfter(ctx context.Context, req *TopNSearch, cursor *Cursor) (*PointInTimeIterator, error) {
+	if cursor != nil {
+		if cursor.PointInTimeID != p.id {
+			return nil, fmt.Errorf("cursor is for point-in-time %s, not %s", cursor.PointInTimeID, p.id)
+		}
+		req.After(cursor.SortValue)
+	}
+	return p.Search(ctx, req)
+}
+
+// Cursor builds a cursor to resume searching this point-in-time
+// after a match with the specified sort value
+func (p *PointInTime) Cursor(sortValue [][]byte) *Cursor {
+	return &Cursor{
+		PointInTimeID: p.id,
+		SortValue:     sortValue,
+	}
+}
+
+// Close removes this point-in-time from the registry, the underlying
+// Reader is closed once any in-flight searches have completed
+func (p *PointInTime) Close() error {
+	p.timer.Stop()
+	return p.expire()
+}
+
+func (p *PointInTime) acquire() bool {
+	p.registry.m.Lock()
+	defer p.registry.m.Unlock()
+	if p.closed {
+		return false
+	}
+	p.active++
+	return true
+}
+
+func (p *PointInTime) release() {
+	p.registry.m.Lock()
+	p.active--
+	closeNow := p.closed && p.active == 0
+	p.registry.m.Unlock()
+	if closeNow {
+		_ = p.reader.Close()
+	}
+}
+
+func (p *PointInTime) leaseExpired() {
+	p.registry.m.Lock()
+	renewed := time.Now().Before(p.expires)
+	p.registry.m.Unlock()
+	// the lease may have been renewed while this timer was firing
+	if !renewed {
+		_ = p.expire()
+	}
+}
+
+func (p *PointInTime) expire() error {
+	p.registry.m.Lock()
+	if p.closed {
+		p.registry.m.Unlock()
+		return nil
+	}
+	p.closed = true
+	delete(p.registry.pits, p.id)
+	closeNow := p.active == 0
+	p.registry.m.Unlock()
+	if closeNow {
+		return p.reader.Close()
+	}
+	return nil
+}
+
+// PointInTimeIterator iterates the matches of a search against a
+// point-in-time, holding the Reader open until the last match has been
+// returned, or the iterator is closed
+type PointInTimeIterator struct {
+	pit      *PointInTime
+	dmi      search.DocumentMatchIterator
+	last     [][]byte
+	released bool
+}
+
+func (i *PointInTimeIterator) Next() (*search.DocumentMatch, error) {
+	if i.released {
+		return nil, nil
+	}
+	next, err := i.dmi.Next()
+	if err != nil || next == nil {
+		i.Close()
+		return nil, err
+	}
+	i.last = next.SortValue
+	return next, nil
+}
+
+func (i *PointInTimeIterator) Aggregations() *search.Bucket {
+	return i.dmi.Aggregations()
+}
+
+// Partial reports whether the results were cut short,
+// such as by a search timeout
+func (i *PointInTimeIterator) Partial() bool {
+	if pdmi, ok := i.dmi.(search.PartialDocumentMatchIterator); ok {
+		return pdmi.Partial()
+	}
+	return false
+}
+
+func (i *PointInTimeIterator) DocumentsExamined() int {
+	if pdmi, ok := i.dmi.(search.PartialDocumentMatchIterator); ok {
+		return pdmi.DocumentsExamined()
+	}
+	return 0
+}
+
+// Cursor returns a cursor to resume searching after the last match
+// returned, or nil if no match has been returned
+func (i *PointInTimeIterator) Cursor() *Cursor {
+	if i.last == nil {
+		return nil
+	}
+	return i.pit.Cursor(i.last)
+}
+
+// Close releases the hold on the Reader, matches already returned
+// must not be used to load stored fields or doc values afterwards
+func (i *PointInTimeIterator) Close() {
+	if !i.released {
+		i.released = true
+		i.pit.release()
+	}
+}
+
+const pointInTimeIDLen = 16
+
+func newPointInTimeID() (string, error) {
+	buf := make([]byte, pointInTimeIDLen)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("error generating point-in-time id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Cursor identifies a position in the results of a search against a
+// point-in-time.  It is transported to clients as an opaque token.
+// For the position to be unambiguous, the sort order should end with a
+// field that is unique per document, such as _id.
+type Cursor struct {
+	PointInTimeID string
+	SortValue     [][]byte
+}
+
+const cursorFormatVersion = 1
+
+// Token encodes the cursor as an opaque, URL-safe string
+func (c *Cursor) Token() string {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, cursorFormatVersion)
+	buf = appendUvarintBytes(buf, []byte(c.PointInTimeID))
+	buf = binary.AppendUvarint(buf, uint64(len(c.SortValue)))
+	for _, val := range c.SortValue {
+		buf = appendUvarintBytes(buf, val)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// ParseCursor decodes a token previously produced by Cursor.Token
+func ParseCursor(token string) (*Cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor token: %w", err)
+	}
+	if len(buf) < 1 || buf[0] != cursorFormatVersion {
+		return nil, fmt.Errorf("invalid cursor token: unsupported version")
+	}
+	buf = buf[1:]
+
+	var pitID []byte
+	pitID, buf, err = readUvarintBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	numValues, n := binary.Uvarint(buf)
+	if n <= 0 || numValues > uint64(len(buf)) {
+		return nil, fmt.Errorf("invalid cursor token: bad sort value count")
+	}
+	buf = buf[n:]
+
+	rv := &Cursor{
+		PointInTimeID: string(pitID),
+		SortValue:     make([][]byte, numValues),
+	}
+	for i := range rv.SortValue {
+		rv.SortValue[i], buf, err = readUvarintBytes(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) > 0 {
+		return nil, fmt.Errorf("invalid cursor token: trailing data")
+	}
+	return rv, nil
+}
+
+func appendUvarintBytes(buf, val []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(val)))
+	return append(buf, val...)
+}
+
+func readUvarintBytes(buf []byte) (val, rest []byte, err error) {
+	l, n := binary.Uvarint(buf)
+	if n <= 0 || l > uint64(len(buf)-n) {
+		return nil, nil, fmt.Errorf("invalid cursor token: truncated")
+	}
+	buf = buf[n:]
+	return buf[:l], buf[l:], nil
+}
@@ -0,0 +1,238 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPointInTimePagination(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		doc := NewDocument(fmt.Sprintf("doc-%d", i))
+		err = indexWriter.Update(doc.ID(), doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatalf("error getting index reader: %v", err)
+	}
+
+	registry := NewPointInTimeRegistry()
+	pit, err := registry.Open(indexReader, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes made after the point-in-time was opened must not be visible
+	doc := NewDocument("doc-new")
+	err = indexWriter.Update(doc.ID(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	var token string
+	for {
+		req := NewTopNSearch(2, NewMatchAllQuery()).SortBy([]string{"_id"})
+		var cursor *Cursor
+		if token != "" {
+			pit, cursor, err = registry.Resume(token, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		dmi, err := pit.SearchAfter(context.Background(), req, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			ids = append(ids, string(next.SortValue[0]))
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dmi.Cursor() == nil {
+			break
+		}
+		token = dmi.Cursor().Token()
+		if strings.ContainsAny(token, "+/=") {
+			t.Errorf("expected url safe token, got %s", token)
+		}
+	}
+
+	expect := []string{"doc-0", "doc-1", "doc-2", "doc-3", "doc-4"}
+	if !reflect.DeepEqual(ids, expect) {
+		t.Errorf("expected %v, got %v", expect, ids)
+	}
+
+	err = registry.Close(pit.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = registry.Get(pit.ID(), 0)
+	if !errors.Is(err, ErrPointInTimeNotFound) {
+		t.Errorf("expected not found after close, got %v", err)
+	}
+}
+
+func TestPointInTimeExpiry(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath)
+	indexWriter, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatalf("error getting index reader: %v", err)
+	}
+
+	registry := NewPointInTimeRegistry()
+	pit, err := registry.Open(indexReader, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for registry.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if registry.Len() != 0 {
+		t.Fatalf("expected point-in-time to expire")
+	}
+
+	_, err = pit.Search(context.Background(), NewTopNSearch(10, NewMatchAllQuery()))
+	if !errors.Is(err, ErrPointInTimeNotFound) {
+		t.Errorf("expected not found after expiry, got %v", err)
+	}
+}
+
+func TestCursorToken(t *testing.T) {
+	cursor := &Cursor{
+		PointInTimeID: "abc",
+		SortValue:     [][]byte{[]byte("x"), {}, {0xff, 0x00}},
+	}
+	parsed, err := ParseCursor(cursor.Token())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cursor, parsed) {
+		t.Errorf("expected %v, got %v", cursor, parsed)
+	}
+
+	_, err = ParseCursor("not-a-token")
+	if err == nil {
+		t.Errorf("expected error parsing invalid token")
+	}
+}
+
+func TestPointInTimeIteratorHoldsReader(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	var docs []*Document
+	for i := 0; i < 3; i++ {
+		docs = append(docs, NewDocument(fmt.Sprintf("doc-%d", i)).
+			AddField(NewKeywordField("name", fmt.Sprintf("name-%d", i)).StoreValue()))
+	}
+	indexWriter := openTestWriterWithDocs(t, tmpIndexPath, docs...)
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatalf("error getting index reader: %v", err)
+	}
+
+	registry := NewPointInTimeRegistry()
+	pit, err := registry.Open(indexReader, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dmi, err := pit.Search(context.Background(), NewTopNSearch(10, NewMatchAllQuery()).SortBy([]string{"_id"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// closing the point-in-time defers closing the reader to the iterator
+	err = pit.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pit.Search(context.Background(), NewTopNSearch(10, NewMatchAllQuery()))
+	if !errors.Is(err, ErrPointInTimeNotFound) {
+		t.Errorf("expected not found after close, got %v", err)
+	}
+
+	var count int
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		doc, derr := next.Document("name")
+		if derr != nil {
+			t.Fatal(derr)
+		}
+		if doc.Text("name") != fmt.Sprintf("name-%d", count) {
+			t.Errorf("expected name-%d, got %s", count, doc.Text("name"))
+		}
+		count++
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 matches, got %d", count)
+	}
+	if pit.active != 0 {
+		t.Errorf("expected the iterator to release the reader once exhausted")
+	}
+}
@@ -0,0 +1,173 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"fmt"
+	"strings"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/searcher"
+)
+
+// QueryPlan describes the searcher built for one query, without running it.
+// The children mirror the structure of the query.
+type QueryPlan struct {
+	Query    string
+	Searcher string
+
+	// Optimization is the kind of composite optimization which replaced
+	// the searchers for several terms or clauses with a single searcher,
+	// for example "conjunction:unadorned", or empty if none applied
+	Optimization string
+
+	// Terms is the number of terms this query expanded to, not
+	// including the terms of its children
+	Terms int
+
+	// EstimatedCount is the searcher's estimate of the number of matches
+	EstimatedCount uint64
+
+	Children []*QueryPlan
+}
+
+// String formats the plan as an indented tree
+func (p *QueryPlan) String() string {
+	var sb strings.Builder
+	p.format(&sb, 0)
+	return sb.String()
+}
+
+func (p *QueryPlan) format(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%s -> %s", strings.Repeat("  ", depth), p.Query, p.Searcher)
+	if p.Optimization != "" {
+		fmt.Fprintf(sb, " optimization: %s", p.Optimization)
+	}
+	fmt.Fprintf(sb, " terms: %d estimate: %d\n", p.Terms, p.EstimatedCount)
+	for _, child := range p.Children {
+		child.format(sb, depth+1)
+	}
+}
+
+// ExplainPlan builds the searchers for the query, using the default search
+// options, and describes them without running the search
+func (r *Reader) ExplainPlan(q Query) (*QueryPlan, error) {
+	return r.ExplainPlanWithOptions(q, SearchOptions{})
+}
+
+// ExplainPlanWithOptions is like ExplainPlan, the options matter because some
+// optimizations only apply when scoring is disabled
+func (r *Reader) ExplainPlanWithOptions(q Query, options SearchOptions) (*QueryPlan, error) {
+	planned := newPlannedQuery(q)
+	s, err := planned.Searcher(r.reader, searchOptionsFromConfig(r.config, options))
+	if err != nil {
+		return nil, err
+	}
+	err = s.Close()
+	if err != nil {
+		return nil, err
+	}
+	return planned.plan, nil
+}
+
+// plannedQuery wraps a query, and the clauses of a boolean query, so
+// that the searchers built for them are described in a plan
+type plannedQuery struct {
+	query Query
+	plan  *QueryPlan
+}
+
+func newPlannedQuery(q Query) *plannedQuery {
+	rv := &plannedQuery{
+		query: q,
+		plan: &QueryPlan{
+			Query: queryTypeName(q),
+		},
+	}
+	if bq, ok := q.(*BooleanQuery); ok {
+		rv.query = bq.wrapClauses(func(clause Query) Query {
+			child := newPlannedQuery(clause)
+			rv.plan.Children = append(rv.plan.Children, child.plan)
+			return child
+		})
+	}
+	return rv
+}
+
+func (p *plannedQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	if parent, ok := i.(*planningReader); ok {
+		// count only the terms of this query
+		i = parent.Reader
+	}
+	s, err := p.query.Searcher(&planningReader{
+		Reader: i,
+		plan:   p.plan,
+	}, options)
+	if err != nil {
+		return nil, err
+	}
+	p.plan.Searcher = fmt.Sprintf("%T", s)
+	p.plan.Optimization = searcherOptimization(s)
+	// estimate now, an optimization may close this searcher
+	p.plan.EstimatedCount = s.Count()
+	return s, nil
+}
+
+func searcherOptimization(s search.Searcher) string {
+	switch s := s.(type) {
+	case *searcher.TermSearcher:
+		return s.Optimization()
+	case *searcher.BooleanSearcher:
+		// the clauses of each kind are combined, and possibly optimized,
+		// before the boolean searcher is built
+		var rv []string
+		must, should, mustNot := s.Clauses()
+		for _, clause := range []struct {
+			name     string
+			searcher search.Searcher
+		}{{"must", must}, {"should", should}, {"must not", mustNot}} {
+			if optimization := searcherOptimization(clause.searcher); optimization != "" {
+				rv = append(rv, clause.name+": "+optimization)
+			}
+		}
+		return strings.Join(rv, ", ")
+	}
+	return ""
+}
+
+// planningReader counts the terms opened through it
+type planningReader struct {
+	search.Reader
+	plan *QueryPlan
+}
+
+func (r *planningReader) Unwrap() search.Reader {
+	return r.Reader
+}
+
+func (r *planningReader) PostingsIterator(term []byte, field string, includeFreq, includeNorm,
+	includeTermVectors bool) (segment.PostingsIterator, error) {
+	r.plan.Terms++
+	return r.Reader.PostingsIterator(term, field, includeFreq, includeNorm, includeTermVectors)
+}
+
+func (r *planningReader) DocumentFrequency(field string, term []byte) (uint64, bool) {
+	if tsr, ok := r.Reader.(search.TermStatsReader); ok {
+		return tsr.DocumentFrequency(field, term)
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExplainPlan(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	var docs []*Document
+	for i := 0; i < 20; i++ {
+		docs = append(docs, NewDocument(fmt.Sprintf("doc-%d", i)).
+			AddField(NewKeywordField("parity", []string{"even", "odd"}[i%2])).
+			AddField(NewKeywordField("small", fmt.Sprintf("%t", i < 8))).
+			AddField(NewKeywordField("tag", fmt.Sprintf("tag-%d", i%5))))
+	}
+	indexWriter := openTestWriterWithDocs(t, tmpIndexPath, docs...)
+	defer func() { _ = indexWriter.Close() }()
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = indexReader.Close() }()
+
+	q := NewBooleanQuery().
+		AddMust(NewTermQuery("even").SetField("parity")).
+		AddMust(NewTermQuery("true").SetField("small")).
+		AddMustNot(NewPrefixQuery("tag-").SetField("tag"))
+
+	plan, err := indexReader.ExplainPlan(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Query != "BooleanQuery" || plan.Searcher != "*searcher.BooleanSearcher" ||
+		len(plan.Children) != 3 {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+	if plan.Optimization != "" {
+		t.Errorf("expected no unadorned optimizations when scoring:\n%s", plan)
+	}
+	even := plan.Children[0]
+	if even.Query != "TermQuery" || even.Terms != 1 || even.EstimatedCount != 10 {
+		t.Errorf("unexpected term plan:\n%s", even)
+	}
+	prefix := plan.Children[2]
+	if prefix.Query != "PrefixQuery" || prefix.Terms != 5 || prefix.EstimatedCount != 20 {
+		t.Errorf("unexpected prefix plan:\n%s", prefix)
+	}
+	if plan.Terms != 0 {
+		t.Errorf("expected boolean query to expand no terms itself, got %d", plan.Terms)
+	}
+
+	plan, err = indexReader.ExplainPlanWithOptions(q, SearchOptions{Score: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "must: conjunction:unadorned"
+	if plan.Optimization != expect {
+		t.Errorf("expected optimization %q, got:\n%s", expect, plan)
+	}
+	if plan.Children[2].Optimization != "disjunction:unadorned" || plan.Children[2].EstimatedCount != 20 {
+		t.Errorf("expected prefix expansion to be optimized:\n%s", plan)
+	}
+}
@@ -0,0 +1,336 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// SearchProfile records the work done by the searcher built for one query.
+// The children mirror the structure of the query, and the measurements
+// of a query include the work done by its children.  The searchers a query
+// combines internally, such as the term searchers of a match query, are
+// children without a Query, their postings are counted by the query.
+type SearchProfile struct {
+	Query    string
+	Searcher string
+
+	BuildTime         time.Duration
+	NextCalls         int
+	NextTime          time.Duration
+	AdvanceCalls      int
+	AdvanceTime       time.Duration
+	DocumentsReturned int
+
+	// PostingsIterators is the number of postings iterators opened,
+	// and Postings is the sum of their counts
+	PostingsIterators int
+	Postings          uint64
+
+	Children []*SearchProfile
+}
+
+// ProfiledDocumentMatchIterator is implemented by the iterator returned from
+// a search with profiling enabled.  The profile is complete once the iterator
+// is exhausted.
+type ProfiledDocumentMatchIterator interface {
+	search.DocumentMatchIterator
+	Profile() *SearchProfile
+}
+
+// String formats the profile as an indented tree
+func (p *SearchProfile) String() string {
+	var sb strings.Builder
+	p.format(&sb, 0)
+	return sb.String()
+}
+
+func (p *SearchProfile) format(sb *strings.Builder, depth int) {
+	name := p.Searcher
+	if p.Query != "" {
+		name = p.Query + " (" + p.Searcher + ")"
+	}
+	fmt.Fprintf(sb, "%s%s build: %v next: %d/%v advance: %d/%v docs: %d postings: %d/%d\n",
+		strings.Repeat("  ", depth), name, p.BuildTime,
+		p.NextCalls, p.NextTime, p.AdvanceCalls, p.AdvanceTime,
+		p.DocumentsReturned, p.PostingsIterators, p.Postings)
+	for _, child := range p.Children {
+		child.format(sb, depth+1)
+	}
+}
+
+// profiledQuery wraps a query, and the clauses of a boolean query,
+// so that the searchers built for them record a profile.  Wrapping the
+// searchers does not prevent the conjunction and disjunction optimizations.
+type profiledQuery struct {
+	query   Query
+	profile *SearchProfile
+}
+
+func newProfiledQuery(q Query) *profiledQuery {
+	rv := &profiledQuery{
+		query: q,
+		profile: &SearchProfile{
+			Query: queryTypeName(q),
+		},
+	}
+	if bq, ok := q.(*BooleanQuery); ok {
+		rv.query = bq.wrapClauses(func(clause Query) Query {
+			child := newProfiledQuery(clause)
+			rv.profile.Children = append(rv.profile.Children, child.profile)
+			return child
+		})
+	}
+	return rv
+}
+
+// wrapClauses returns a copy of the boolean query with every clause
+// replaced by the result of wrap, in must, should, must not order
+func (q *BooleanQuery) wrapClauses(wrap func(Query) Query) *BooleanQuery {
+	wrapSlice := func(clauses querySlice) querySlice {
+		if clauses == nil {
+			return nil
+		}
+		rv := make(querySlice, len(clauses))
+		for i, clause := range clauses {
+			rv[i] = wrap(clause)
+		}
+		return rv
+	}
+	rv := *q
+	rv.musts = wrapSlice(q.musts)
+	rv.shoulds = wrapSlice(q.shoulds)
+	rv.mustNots = wrapSlice(q.mustNots)
+	return &rv
+}
+
+func queryTypeName(q Query) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", q), "*bluge.")
+}
+
+func (p *profiledQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	start := time.Now()
+	s, err := p.query.Searcher(&profilingReader{
+		Reader:  i,
+		profile: p.profile,
+	}, options)
+	p.profile.BuildTime += time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	p.profile.Searcher = fmt.Sprintf("%T", s)
+	profileChildren(s, p.profile)
+	return &profiledSearcher{
+		Searcher: s,
+		profile:  p.profile,
+	}, nil
+}
+
+// profileChildren wraps the searchers combined by the searcher, which are
+// not already profiled for a query, adding their profiles to the parent
+func profileChildren(s search.Searcher, parent *SearchProfile) {
+	ps, ok := s.(search.ParentSearcher)
+	if !ok {
+		return
+	}
+	ps.WrapChildren(func(child search.Searcher) search.Searcher {
+		if containsProfiled(child) {
+			// the profiles of the clauses already mirror the query
+			profileChildren(child, parent)
+			return child
+		}
+		profile := &SearchProfile{
+			Searcher: fmt.Sprintf("%T", child),
+		}
+		parent.Children = append(parent.Children, profile)
+		profileChildren(child, profile)
+		return &profiledSearcher{
+			Searcher: child,
+			profile:  profile,
+		}
+	})
+}
+
+func containsProfiled(s search.Searcher) bool {
+	if _, ok := s.(*profiledSearcher); ok {
+		return true
+	}
+	if ps, ok := s.(search.ParentSearcher); ok {
+		for _, child := range ps.Children() {
+			if containsProfiled(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searcherProfile returns the profile recorded by the searcher,
+// or nil if it is not profiled
+func searcherProfile(s search.Searcher) *SearchProfile {
+	for {
+		if profiled, ok := s.(*profiledSearcher); ok {
+			return profiled.profile
+		}
+		w, ok := s.(search.SearcherWrapper)
+		if !ok {
+			return nil
+		}
+		s = w.Unwrap()
+	}
+}
+
+// multiSearchProfile combines the profiles of the searchers built for each
+// reader of a MultiSearch as its children, or returns nil if they are
+// not profiled
+func multiSearchProfile(searchers []search.Searcher) *SearchProfile {
+	rv := &SearchProfile{
+		Query:    "MultiSearch",
+		Searcher: "MultiSearcherList",
+	}
+	for _, s := range searchers {
+		if s == nil {
+			// the reader failed in a tolerant MultiSearch
+			continue
+		}
+		profile := searcherProfile(s)
+		if profile == nil {
+			return nil
+		}
+		rv.Children = append(rv.Children, profile)
+	}
+	if len(rv.Children) == 0 {
+		return nil
+	}
+	rv.sumChildren()
+	return rv
+}
+
+// sumChildren sets the measurements to the sum of those of the children
+func (p *SearchProfile) sumChildren() {
+	p.BuildTime, p.NextCalls, p.NextTime, p.AdvanceCalls, p.AdvanceTime = 0, 0, 0, 0, 0
+	p.DocumentsReturned, p.PostingsIterators, p.Postings = 0, 0, 0
+	for _, child := range p.Children {
+		p.BuildTime += child.BuildTime
+		p.NextCalls += child.NextCalls
+		p.NextTime += child.NextTime
+		p.AdvanceCalls += child.AdvanceCalls
+		p.AdvanceTime += child.AdvanceTime
+		p.DocumentsReturned += child.DocumentsReturned
+		p.PostingsIterators += child.PostingsIterators
+		p.Postings += child.Postings
+	}
+}
+
+type profiledSearcher struct {
+	search.Searcher
+	profile *SearchProfile
+}
+
+func (s *profiledSearcher) Unwrap() search.Searcher {
+	return s.Searcher
+}
+
+func (s *profiledSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	start := time.Now()
+	rv, err := s.Searcher.Next(ctx)
+	s.profile.NextTime += time.Since(start)
+	s.profile.NextCalls++
+	if rv != nil {
+		s.profile.DocumentsReturned++
+	}
+	return rv, err
+}
+
+func (s *profiledSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	start := time.Now()
+	rv, err := s.Searcher.Advance(ctx, number)
+	s.profile.AdvanceTime += time.Since(start)
+	s.profile.AdvanceCalls++
+	if rv != nil {
+		s.profile.DocumentsReturned++
+	}
+	return rv, err
+}
+
+func (s *profiledSearcher) Optimize(kind string, octx segment.OptimizableContext) (
+	segment.OptimizableContext, error) {
+	if o, ok := s.Searcher.(segment.Optimizable); ok {
+		return o.Optimize(kind, octx)
+	}
+	return nil, nil
+}
+
+// profilingReader records the postings iterators opened through it
+type profilingReader struct {
+	search.Reader
+	profile *SearchProfile
+}
+
+func (r *profilingReader) Unwrap() search.Reader {
+	return r.Reader
+}
+
+func (r *profilingReader) PostingsIterator(term []byte, field string, includeFreq, includeNorm,
+	includeTermVectors bool) (segment.PostingsIterator, error) {
+	rv, err := r.Reader.PostingsIterator(term, field, includeFreq, includeNorm, includeTermVectors)
+	if err != nil {
+		return nil, err
+	}
+	r.profile.PostingsIterators++
+	r.profile.Postings += rv.Count()
+	return rv, nil
+}
+
+func (r *profilingReader) DocumentFrequency(field string, term []byte) (uint64, bool) {
+	if tsr, ok := r.Reader.(search.TermStatsReader); ok {
+		return tsr.DocumentFrequency(field, term)
+	}
+	return 0, false
+}
+
+type profiledIterator struct {
+	search.DocumentMatchIterator
+	profile *SearchProfile
+	// sum is set when the profile combines those of several readers
+	sum bool
+}
+
+func (i *profiledIterator) Profile() *SearchProfile {
+	if i.sum {
+		i.profile.sumChildren()
+	}
+	return i.profile
+}
+
+func (i *profiledIterator) Partial() bool {
+	if pdmi, ok := i.DocumentMatchIterator.(search.PartialDocumentMatchIterator); ok {
+		return pdmi.Partial()
+	}
+	return false
+}
+
+func (i *profiledIterator) DocumentsExamined() int {
+	if pdmi, ok := i.DocumentMatchIterator.(search.PartialDocumentMatchIterator); ok {
+		return pdmi.DocumentsExamined()
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSearchProfile(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	var docs []*Document
+	for i := 0; i < 10; i++ {
+		docs = append(docs, NewDocument(fmt.Sprintf("doc-%d", i)).
+			AddField(NewKeywordField("parity", []string{"even", "odd"}[i%2])).
+			AddField(NewKeywordField("small", fmt.Sprintf("%t", i < 4))))
+	}
+	indexWriter := openTestWriterWithDocs(t, tmpIndexPath, docs...)
+	defer func() { _ = indexWriter.Close() }()
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = indexReader.Close() }()
+
+	q := NewBooleanQuery().
+		AddMust(NewTermQuery("even").SetField("parity")).
+		AddMustNot(NewTermQuery("true").SetField("small")).
+		AddMustNot(NewMatchNoneQuery())
+
+	dmi, err := indexReader.Search(context.Background(), NewTopNSearch(10, q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := countHits(dmi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dmi, err = indexReader.Search(context.Background(), NewTopNSearch(10, q).Profile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, err := countHits(dmi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits != expect || hits != 3 {
+		t.Fatalf("expected %d hits with profiling, got %d", expect, hits)
+	}
+
+	profiled, ok := dmi.(ProfiledDocumentMatchIterator)
+	if !ok {
+		t.Fatalf("expected profiled iterator, got %T", dmi)
+	}
+	profile := profiled.Profile()
+	if profile.Query != "BooleanQuery" || len(profile.Children) != 3 {
+		t.Fatalf("unexpected profile:\n%s", profile)
+	}
+	if profile.DocumentsReturned != hits {
+		t.Errorf("expected root to return %d documents, got %d", hits, profile.DocumentsReturned)
+	}
+	if profile.NextCalls != hits+1 {
+		t.Errorf("expected %d calls to next, got %d", hits+1, profile.NextCalls)
+	}
+
+	must := profile.Children[0]
+	if must.Query != "TermQuery" || must.PostingsIterators != 1 || must.Postings != 5 {
+		t.Errorf("unexpected must profile: %s", must)
+	}
+	if must.DocumentsReturned == 0 || must.Searcher == "" {
+		t.Errorf("expected must clause to have returned documents: %s", must)
+	}
+	if profile.PostingsIterators != 2 || profile.Postings != 9 {
+		t.Errorf("expected root to include postings of its children: %s", profile)
+	}
+	if !strings.Contains(profile.String(), "\n  TermQuery") {
+		t.Errorf("expected indented children:\n%s", profile)
+	}
+}
+
+func TestSearchProfileInternalSearchers(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+
+	var docs1, docs2 []*Document
+	for i := 0; i < 10; i++ {
+		doc := NewDocument(fmt.Sprintf("doc-%d", i)).
+			AddField(NewTextField("desc", []string{"quick fox", "lazy dog"}[i%2]))
+		if i < 6 {
+			docs1 = append(docs1, doc)
+		} else {
+			docs2 = append(docs2, doc)
+		}
+	}
+	indexWriter := openTestWriterWithDocs(t, tmpIndexPath, docs1...)
+	defer func() { _ = indexWriter.Close() }()
+	indexWriter2 := openTestWriterWithDocs(t, tmpIndexPath2, docs2...)
+	defer func() { _ = indexWriter2.Close() }()
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = indexReader.Close() }()
+	indexReader2, err := indexWriter2.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = indexReader2.Close() }()
+
+	// the term searchers of the match query are profiled as its children
+	q := NewMatchQuery("quick dog").SetField("desc")
+	dmi, err := indexReader.Search(context.Background(), NewTopNSearch(10, q).Profile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, err := countHits(dmi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := dmi.(ProfiledDocumentMatchIterator).Profile()
+	if profile.Query != "MatchQuery" || len(profile.Children) != 1 ||
+		len(profile.Children[0].Children) != 2 {
+		t.Fatalf("unexpected profile:\n%s", profile)
+	}
+	var childDocs int
+	for _, child := range profile.Children[0].Children {
+		if child.Query != "" || child.Searcher != "*searcher.TermSearcher" {
+			t.Errorf("expected internal term searcher, got:\n%s", child)
+		}
+		childDocs += child.DocumentsReturned
+	}
+	if hits != 6 || childDocs != hits {
+		t.Errorf("expected term searchers to return %d documents, got %d", hits, childDocs)
+	}
+
+	// each reader of a MultiSearch has its own profile
+	for _, options := range []MultiSearchOptions{{}, {Parallel: true}} {
+		dmi, err = MultiSearchWithOptions(context.Background(), NewTopNSearch(10, q).Profile(),
+			options, indexReader, indexReader2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits, err = countHits(dmi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiled, ok := dmi.(ProfiledDocumentMatchIterator)
+		if !ok {
+			t.Fatalf("expected profiled iterator, got %T", dmi)
+		}
+		profile = profiled.Profile()
+		if profile == nil || len(profile.Children) != 2 {
+			t.Fatalf("expected a profile for each reader, got:\n%s", profile)
+		}
+		if hits != 10 || profile.DocumentsReturned != hits {
+			t.Errorf("expected %d documents returned, got %d", hits, profile.DocumentsReturned)
+		}
+	}
+}
@@ -229,7 +229,7 @@ func (q *BooleanQuery) Searcher(i search.Reader, options search.SearcherOptions)
 }
 
 func replaceMatchNoneWithNil(s search.Searcher) search.Searcher {
-	if _, ok := s.(*searcher.MatchNoneSearcher); ok {
+	if _, ok := search.UnwrapSearcher(s).(*searcher.MatchNoneSearcher); ok {
 		return nil
 	}
 	return s
@@ -76,11 +76,19 @@ func (r *Reader) Search(ctx context.Context, req SearchRequest) (search.Document
 	}
 
 	var dmItr search.DocumentMatchIterator
-	dmItr, err = collector.Collect(ctx, req.Aggregations(), searcher)
+	aggs := req.Aggregations().WithSearcherOptions(aggregationSearchOptions(r.config))
+	dmItr, err = collector.Collect(ctx, aggs, searcher)
 	if err != nil {
 		return nil, err
 	}
 
+	if profile := searcherProfile(searcher); profile != nil {
+		dmItr = &profiledIterator{
+			DocumentMatchIterator: dmItr,
+			profile:               profile,
+		}
+	}
+
 	// FIXME search stats on reader?
 
 	return dmItr, nil
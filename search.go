@@ -15,6 +15,9 @@
 package bluge
 
 import (
+	"sort"
+	"time"
+
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
 	"github.com/blugelabs/bluge/search/collector"
@@ -31,6 +34,7 @@ type SearchOptions struct {
 	ExplainScores    bool
 	IncludeLocations bool
 	Score            string // FIXME go away
+	Profile          bool
 }
 
 type BaseSearch struct {
@@ -52,6 +56,9 @@ func (b BaseSearch) Aggregations() search.Aggregations {
 }
 
 func (b BaseSearch) Searcher(i search.Reader, config Config) (search.Searcher, error) {
+	if b.options.Profile {
+		return newProfiledQuery(b.query).Searcher(i, searchOptionsFromConfig(config, b.options))
+	}
 	return b.query.Searcher(i, searchOptionsFromConfig(config, b.options))
 }
 
@@ -64,6 +71,8 @@ type TopNSearch struct {
 	sort     search.SortOrder
 	after    [][]byte
 	reversed bool
+	timeout  time.Duration
+	deadline time.Time
 }
 
 // NewTopNSearch creates a search which will find the matches and return the first N when ordered by the
@@ -161,12 +170,34 @@ func (s *TopNSearch) IncludeLocations() *TopNSearch {
 	return s
 }
 
+// SetTimeout limits how long the search spends collecting matches, once the
+// timeout elapses the matches and aggregations collected so far are returned
+// and the results are flagged as partial (see search.PartialDocumentMatchIterator)
+func (s *TopNSearch) SetTimeout(timeout time.Duration) *TopNSearch {
+	s.timeout = timeout
+	return s
+}
+
+// SetDeadline is like SetTimeout, but stops collecting matches at a fixed point in time
+func (s *TopNSearch) SetDeadline(deadline time.Time) *TopNSearch {
+	s.deadline = deadline
+	return s
+}
+
+// Profile records the work done by the searcher for each query in the
+// query tree, see ProfiledDocumentMatchIterator
+func (s *TopNSearch) Profile() *TopNSearch {
+	s.options.Profile = true
+	return s
+}
+
 func (s *TopNSearch) SetScore(mode string) *TopNSearch {
 	s.options.Score = mode
 	return s
 }
 
 func (s *TopNSearch) Collector() search.Collector {
+	var rv *collector.TopNCollector
 	if s.after != nil {
 		collectorSort := s.sort
 		if s.reversed {
@@ -174,10 +205,74 @@ func (s *TopNSearch) Collector() search.Collector {
 			collectorSort = s.sort.Copy()
 			collectorSort.Reverse()
 		}
-		rv := collector.NewTopNCollectorAfter(s.n, collectorSort, s.after, s.reversed)
-		return rv
+		rv = collector.NewTopNCollectorAfter(s.n, collectorSort, s.after, s.reversed)
+	} else {
+		rv = collector.NewTopNCollector(s.n, s.from, s.sort)
+	}
+	return rv.SetDeadline(s.collectDeadline())
+}
+
+// readerCollectors builds one collector per reader, for searching several
+// readers independently, along with a function merging their results
+// into the page described by this request.  Each collector keeps enough
+// matches for the whole page, since any one reader may supply all of it.
+func (s *TopNSearch) readerCollectors(numReaders int) (
+	rv []search.Collector, merge func(search.DocumentMatchCollection) search.DocumentMatchCollection) {
+	collectorSort := s.sort
+	if s.after != nil && s.reversed {
+		// preserve original sort order in the request
+		collectorSort = s.sort.Copy()
+		collectorSort.Reverse()
+	}
+	deadline := s.collectDeadline()
+	for i := 0; i < numReaders; i++ {
+		var coll *collector.TopNCollector
+		if s.after != nil {
+			coll = collector.NewTopNCollectorAfter(s.n, collectorSort, s.after, s.reversed)
+		} else {
+			coll = collector.NewTopNCollector(s.n+s.from, 0, collectorSort)
+		}
+		rv = append(rv, coll.SetDeadline(deadline))
 	}
-	return collector.NewTopNCollector(s.n, s.from, s.sort)
+
+	merge = func(matches search.DocumentMatchCollection) search.DocumentMatchCollection {
+		sort.Slice(matches, func(i, j int) bool {
+			return collectorSort.Compare(matches[i], matches[j]) < 0
+		})
+		if s.after != nil {
+			if len(matches) > s.n {
+				matches = matches[:s.n]
+			}
+			if s.reversed {
+				for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+					matches[i], matches[j] = matches[j], matches[i]
+				}
+			}
+			return matches
+		}
+		if s.from >= len(matches) {
+			return nil
+		}
+		matches = matches[s.from:]
+		if len(matches) > s.n {
+			matches = matches[:s.n]
+		}
+		return matches
+	}
+	return rv, merge
+}
+
+// collectDeadline returns the earliest of the configured deadline
+// and the configured timeout measured from now
+func (s *TopNSearch) collectDeadline() time.Time {
+	deadline := s.deadline
+	if s.timeout > 0 {
+		timeoutDeadline := time.Now().Add(s.timeout)
+		if deadline.IsZero() || timeoutDeadline.Before(deadline) {
+			deadline = timeoutDeadline
+		}
+	}
+	return deadline
 }
 
 func searchOptionsFromConfig(config Config, options SearchOptions) search.SearcherOptions {
@@ -196,6 +291,14 @@ func searchOptionsFromConfig(config Config, options SearchOptions) search.Search
 	}
 }
 
+// aggregationSearchOptions are the options for the searchers built by
+// aggregations, which only need the matches and not their scores
+func aggregationSearchOptions(config Config) search.SearcherOptions {
+	return searchOptionsFromConfig(config, SearchOptions{
+		Score: "none",
+	})
+}
+
 func (s *TopNSearch) AddAggregation(name string, aggregation search.Aggregation) {
 	s.aggregations.Add(name, aggregation)
 }
@@ -234,6 +337,13 @@ func (s *AllMatches) IncludeLocations() *AllMatches {
 	return s
 }
 
+// Profile records the work done by the searcher for each query in the
+// query tree, see ProfiledDocumentMatchIterator
+func (s *AllMatches) Profile() *AllMatches {
+	s.options.Profile = true
+	return s
+}
+
 func (s *AllMatches) Collector() search.Collector {
 	return collector.NewAllCollector()
 }
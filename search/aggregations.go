@@ -15,7 +15,10 @@
 package search
 
 import (
+	"sort"
 	"time"
+
+	"github.com/blugelabs/bluge/numeric/geo"
 )
 
 type Aggregation interface {
@@ -37,6 +40,49 @@ func (a Aggregations) Fields() []string {
 	return rv
 }
 
+// ReadersAggregation is implemented by aggregations which look up
+// statistics in every reader searched, not only in the readers of the
+// documents they consume
+type ReadersAggregation interface {
+	Aggregation
+	// WithReaders returns a copy of the aggregation using the readers
+	WithReaders(readers []Reader) Aggregation
+}
+
+// WithReaders returns a copy of the aggregations, where those which look
+// up statistics in the readers searched use the readers provided
+func (a Aggregations) WithReaders(readers []Reader) Aggregations {
+	rv := make(Aggregations, len(a))
+	for name, aggregation := range a {
+		if readersAggregation, ok := aggregation.(ReadersAggregation); ok {
+			aggregation = readersAggregation.WithReaders(readers)
+		}
+		rv[name] = aggregation
+	}
+	return rv
+}
+
+// SearcherOptionsAggregation is implemented by aggregations which build
+// searchers of their own, so that they use the options of the search
+type SearcherOptionsAggregation interface {
+	Aggregation
+	// WithSearcherOptions returns a copy of the aggregation using the options
+	WithSearcherOptions(options SearcherOptions) Aggregation
+}
+
+// WithSearcherOptions returns a copy of the aggregations, where those
+// which build searchers use the options provided
+func (a Aggregations) WithSearcherOptions(options SearcherOptions) Aggregations {
+	rv := make(Aggregations, len(a))
+	for name, aggregation := range a {
+		if optionsAggregation, ok := aggregation.(SearcherOptionsAggregation); ok {
+			aggregation = optionsAggregation.WithSearcherOptions(options)
+		}
+		rv[name] = aggregation
+	}
+	return rv
+}
+
 type Calculator interface {
 	Consume(*DocumentMatch)
 	Finish()
@@ -48,16 +94,54 @@ type MetricCalculator interface {
 	Value() float64
 }
 
+// MultiMetricCalculator computes several metrics in one pass
+type MultiMetricCalculator interface {
+	Calculator
+	Metrics() []string
+	MetricValue(name string) float64
+}
+
 type DurationCalculator interface {
 	Calculator
 	Duration() time.Duration
 }
 
+// GeoBoundsCalculator computes the bounding box of geo points, with
+// nil corners when there are no points.  The top left longitude is
+// greater than the bottom right longitude when the box wraps around
+// the antimeridian.
+type GeoBoundsCalculator interface {
+	Calculator
+	Bounds() (topLeft, bottomRight *geo.Point)
+}
+
+// GeoCentroidCalculator computes the centroid of geo points,
+// nil when there are no points
+type GeoCentroidCalculator interface {
+	Calculator
+	Centroid() *geo.Point
+}
+
 type BucketCalculator interface {
 	Calculator
 	Buckets() []*Bucket
 }
 
+// ErrorCalculator is implemented by calculators which can fail, the
+// search returns the error once the aggregations are finished
+type ErrorCalculator interface {
+	Calculator
+	Err() error
+}
+
+// SiblingCalculator is implemented by calculators computed from the other
+// calculators of the bucket holding them, like sibling pipelines, the
+// bucket is set whenever the calculator is added to one
+type SiblingCalculator interface {
+	Calculator
+	SetBucket(bucket *Bucket)
+}
+
 type Bucket struct {
 	name         string
 	aggregations map[string]Calculator
@@ -70,6 +154,9 @@ func NewBucket(name string, aggregations map[string]Aggregation) *Bucket {
 	}
 	for name, agg := range aggregations {
 		rv.aggregations[name] = agg.Calculator()
+		if sibling, ok := rv.aggregations[name].(SiblingCalculator); ok {
+			sibling.SetBucket(rv)
+		}
 	}
 	return rv
 }
@@ -79,7 +166,7 @@ func (b *Bucket) Merge(other *Bucket) {
 		if thisCalculator, ok := b.aggregations[otherAggName]; ok {
 			thisCalculator.Merge(otherCalculator)
 		} else {
-			b.aggregations[otherAggName] = otherCalculator
+			b.SetAggregation(otherAggName, otherCalculator)
 		}
 	}
 }
@@ -100,6 +187,31 @@ func (b *Bucket) Finish() {
 	}
 }
 
+// Err returns the error of the first of the calculators of the bucket,
+// by name, which failed, including those of the buckets they hold
+func (b *Bucket) Err() error {
+	names := make([]string, 0, len(b.aggregations))
+	for name := range b.aggregations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if calc, ok := b.aggregations[name].(ErrorCalculator); ok {
+			if err := calc.Err(); err != nil {
+				return err
+			}
+		}
+		if calc, ok := b.aggregations[name].(BucketCalculator); ok {
+			for _, bucket := range calc.Buckets() {
+				if err := bucket.Err(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (b *Bucket) Aggregations() map[string]Calculator {
 	return b.aggregations
 }
@@ -131,6 +243,35 @@ func (b *Bucket) Metric(name string) float64 {
 	return 0
 }
 
+// MultiMetric returns the value of one of the metrics of the named
+// multi metric aggregation
+func (b *Bucket) MultiMetric(name, metric string) float64 {
+	if agg, ok := b.aggregations[name]; ok {
+		if calc, ok := agg.(MultiMetricCalculator); ok {
+			return calc.MetricValue(metric)
+		}
+	}
+	return 0
+}
+
+func (b *Bucket) GeoBounds(name string) (topLeft, bottomRight *geo.Point) {
+	if agg, ok := b.aggregations[name]; ok {
+		if calc, ok := agg.(GeoBoundsCalculator); ok {
+			return calc.Bounds()
+		}
+	}
+	return nil, nil
+}
+
+func (b *Bucket) GeoCentroid(name string) *geo.Point {
+	if agg, ok := b.aggregations[name]; ok {
+		if calc, ok := agg.(GeoCentroidCalculator); ok {
+			return calc.Centroid()
+		}
+	}
+	return nil
+}
+
 func (b *Bucket) Buckets(name string) []*Bucket {
 	if agg, ok := b.aggregations[name]; ok {
 		if calc, ok := agg.(BucketCalculator); ok {
@@ -143,3 +284,12 @@ func (b *Bucket) Buckets(name string) []*Bucket {
 func (b *Bucket) Aggregation(name string) Calculator {
 	return b.aggregations[name]
 }
+
+// SetAggregation replaces the calculator of the named aggregation,
+// for results computed from the finished buckets, like pipelines
+func (b *Bucket) SetAggregation(name string, calculator Calculator) {
+	b.aggregations[name] = calculator
+	if sibling, ok := calculator.(SiblingCalculator); ok {
+		sibling.SetBucket(b)
+	}
+}
@@ -21,7 +21,7 @@ import (
 	segment "github.com/strivewrt/bluge_segment_api"
 
 	"github.com/blugelabs/bluge/numeric"
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 func TestAggregations(t *testing.T) {
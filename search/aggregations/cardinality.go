@@ -16,7 +16,7 @@ package aggregations
 
 import (
 	"github.com/axiomhq/hyperloglog"
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type CardinalityMetric struct {
@@ -0,0 +1,407 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// CompositeKey holds the value of each source of a composite bucket,
+// a string for terms, a float64 for histograms and a time.Time for
+// date histograms
+type CompositeKey []interface{}
+
+// CompositeSource provides one of the values of the keys of a
+// composite aggregation
+type CompositeSource struct {
+	name    string
+	fields  []string
+	values  func(d *search.DocumentMatch) []interface{}
+	valid   func(v interface{}) bool
+	compare func(a, b interface{}) int
+	format  func(v interface{}) string
+	desc    bool
+}
+
+// CompositeTerms uses each distinct term of the source
+func CompositeTerms(name string, src search.TextValuesSource) *CompositeSource {
+	return &CompositeSource{
+		name:   name,
+		fields: src.Fields(),
+		values: func(d *search.DocumentMatch) []interface{} {
+			var rv []interface{}
+			for _, term := range src.Values(d) {
+				rv = appendDistinct(rv, string(term))
+			}
+			return rv
+		},
+		valid: func(v interface{}) bool {
+			_, ok := v.(string)
+			return ok
+		},
+		compare: func(a, b interface{}) int {
+			return strings.Compare(a.(string), b.(string))
+		},
+		format: func(v interface{}) string {
+			return v.(string)
+		},
+	}
+}
+
+// CompositeHistogram uses the lower bound of the buckets of the
+// histogram containing the values, only its interval and offset apply
+func CompositeHistogram(name string, histogram *HistogramAggregation) *CompositeSource {
+	return &CompositeSource{
+		name:   name,
+		fields: histogram.src.Fields(),
+		values: func(d *search.DocumentMatch) []interface{} {
+			var rv []interface{}
+			for _, val := range histogram.src.Numbers(d) {
+				if math.IsNaN(val) || math.IsInf(val, 0) {
+					continue
+				}
+				if ordinal, ok := histogramOrdinal(val, histogram.interval, histogram.offset); ok {
+					rv = appendDistinct(rv, histogramKey(ordinal, histogram.interval, histogram.offset))
+				}
+			}
+			return rv
+		},
+		valid: func(v interface{}) bool {
+			_, ok := v.(float64)
+			return ok
+		},
+		compare: func(a, b interface{}) int {
+			switch {
+			case a.(float64) < b.(float64):
+				return -1
+			case a.(float64) > b.(float64):
+				return 1
+			}
+			return 0
+		},
+		format: func(v interface{}) string {
+			return strconv.FormatFloat(v.(float64), 'f', -1, 64)
+		},
+	}
+}
+
+// CompositeDateHistogram uses the start of the buckets of the date
+// histogram containing the dates, only its interval, location and
+// offset apply
+func CompositeDateHistogram(name string, histogram *DateHistogramAggregation) *CompositeSource {
+	rounding := histogram.rounding()
+	return &CompositeSource{
+		name:   name,
+		fields: histogram.src.Fields(),
+		values: func(d *search.DocumentMatch) []interface{} {
+			var rv []interface{}
+			for _, val := range histogram.src.Dates(d) {
+				rv = appendDistinct(rv, rounding.start(rounding.ordinal(val)))
+			}
+			return rv
+		},
+		valid: func(v interface{}) bool {
+			_, ok := v.(time.Time)
+			return ok
+		},
+		compare: func(a, b interface{}) int {
+			switch {
+			case a.(time.Time).Before(b.(time.Time)):
+				return -1
+			case a.(time.Time).After(b.(time.Time)):
+				return 1
+			}
+			return 0
+		},
+		format: func(v interface{}) string {
+			return v.(time.Time).Format(time.RFC3339)
+		},
+	}
+}
+
+func appendDistinct(values []interface{}, value interface{}) []interface{} {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// Desc orders the buckets by descending values of the source
+func (s *CompositeSource) Desc() *CompositeSource {
+	s.desc = true
+	return s
+}
+
+func (s *CompositeSource) Name() string {
+	return s.name
+}
+
+// CompositeAggregation buckets the documents by every combination of the
+// values of its sources, in key order, size buckets at a time.  Documents
+// without a value for one of the sources are not bucketed.  The key of
+// the last bucket is passed to After to page through the next ones.
+type CompositeAggregation struct {
+	size         int
+	sources      []*CompositeSource
+	after        CompositeKey
+	aggregations map[string]search.Aggregation
+	err          error
+}
+
+func Composite(size int, sources ...*CompositeSource) *CompositeAggregation {
+	return &CompositeAggregation{
+		size:    size,
+		sources: sources,
+		aggregations: map[string]search.Aggregation{
+			"count": CountMatches(),
+		},
+	}
+}
+
+// After only includes the buckets with keys following the key,
+// which has a value for each source of the type of its keys.  When it
+// does not, the calculator consumes no documents and reports the error
+// from Err
+func (a *CompositeAggregation) After(key CompositeKey) *CompositeAggregation {
+	a.after = key
+	a.err = nil
+	if key == nil {
+		return a
+	}
+	if len(key) != len(a.sources) {
+		a.err = fmt.Errorf("composite after key has %d values for %d sources", len(key), len(a.sources))
+		return a
+	}
+	for i, source := range a.sources {
+		if !source.valid(key[i]) {
+			a.err = fmt.Errorf("composite after key has %T value %v for source %s", key[i], key[i], source.name)
+			return a
+		}
+	}
+	return a
+}
+
+func (a *CompositeAggregation) AddAggregation(name string, agg search.Aggregation) *CompositeAggregation {
+	a.aggregations[name] = agg
+	return a
+}
+
+func (a *CompositeAggregation) Fields() []string {
+	var rv []string
+	for _, source := range a.sources {
+		rv = append(rv, source.fields...)
+	}
+	for _, agg := range a.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (a *CompositeAggregation) Calculator() search.Calculator {
+	return &CompositeCalculator{
+		agg:     a,
+		entries: make(map[string]*compositeEntry),
+	}
+}
+
+func (a *CompositeAggregation) compare(x, y CompositeKey) int {
+	for i, source := range a.sources {
+		c := source.compare(x[i], y[i])
+		if source.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// id identifies the key in a map, the values are quoted or numeric,
+// so the separator cannot be confused with them
+func (a *CompositeAggregation) id(key CompositeKey) string {
+	parts := make([]string, len(key))
+	for i, value := range key {
+		switch value := value.(type) {
+		case string:
+			parts[i] = strconv.Quote(value)
+		case float64:
+			parts[i] = strconv.FormatFloat(value, 'g', -1, 64)
+		case time.Time:
+			parts[i] = strconv.FormatInt(value.UnixNano(), 10)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (a *CompositeAggregation) name(key CompositeKey) string {
+	parts := make([]string, len(key))
+	for i, source := range a.sources {
+		parts[i] = source.format(key[i])
+	}
+	return strings.Join(parts, "|")
+}
+
+type compositeEntry struct {
+	key    CompositeKey
+	bucket *search.Bucket
+}
+
+// CompositeCalculator keeps the buckets of the lowest keys.  Once more than
+// size keys have been seen, those after the size lowest are dropped and
+// the highest remaining key bounds the keys accepted afterwards, a dropped
+// key can never return.  The size lowest keys of each calculator include
+// those of the merged result, so the buckets kept are exact.
+type CompositeCalculator struct {
+	agg     *CompositeAggregation
+	entries map[string]*compositeEntry
+	bound   CompositeKey
+	sorted  []*compositeEntry
+	buckets []*search.Bucket
+	keys    []CompositeKey
+	values  [][]interface{}
+}
+
+func (c *CompositeCalculator) Consume(d *search.DocumentMatch) {
+	if len(c.agg.sources) == 0 || c.agg.size <= 0 || c.Err() != nil {
+		return
+	}
+	c.values = c.values[:0]
+	for _, source := range c.agg.sources {
+		values := source.values(d)
+		if len(values) == 0 {
+			return
+		}
+		c.values = append(c.values, values)
+	}
+	c.consumeCombinations(d, make(CompositeKey, 0, len(c.agg.sources)))
+	if len(c.entries) > 2*c.agg.size {
+		c.trim()
+	}
+}
+
+func (c *CompositeCalculator) consumeCombinations(d *search.DocumentMatch, prefix CompositeKey) {
+	if len(prefix) == len(c.agg.sources) {
+		c.consumeKey(d, prefix)
+		return
+	}
+	for _, value := range c.values[len(prefix)] {
+		c.consumeCombinations(d, append(prefix, value))
+	}
+}
+
+func (c *CompositeCalculator) consumeKey(d *search.DocumentMatch, key CompositeKey) {
+	if c.agg.after != nil && c.agg.compare(key, c.agg.after) <= 0 {
+		return
+	}
+	if c.bound != nil && c.agg.compare(key, c.bound) > 0 {
+		return
+	}
+	id := c.agg.id(key)
+	entry, ok := c.entries[id]
+	if !ok {
+		entry = &compositeEntry{
+			key:    append(CompositeKey(nil), key...),
+			bucket: search.NewBucket(c.agg.name(key), c.agg.aggregations),
+		}
+		c.entries[id] = entry
+	}
+	entry.bucket.Consume(d)
+}
+
+// trim keeps the entries of the size lowest keys
+func (c *CompositeCalculator) trim() {
+	c.sorted = c.sorted[:0]
+	for _, entry := range c.entries {
+		c.sorted = append(c.sorted, entry)
+	}
+	sort.Slice(c.sorted, func(i, j int) bool {
+		return c.agg.compare(c.sorted[i].key, c.sorted[j].key) < 0
+	})
+	if len(c.sorted) > c.agg.size {
+		for _, entry := range c.sorted[c.agg.size:] {
+			delete(c.entries, c.agg.id(entry.key))
+		}
+		c.sorted = c.sorted[:c.agg.size]
+	}
+	// once there are size keys, any higher key can never be kept
+	if len(c.sorted) > 0 && len(c.sorted) == c.agg.size {
+		c.bound = c.sorted[len(c.sorted)-1].key
+	}
+}
+
+func (c *CompositeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*CompositeCalculator); ok {
+		for id, otherEntry := range other.entries {
+			if entry, ok := c.entries[id]; ok {
+				entry.bucket.Merge(otherEntry.bucket)
+			} else {
+				c.entries[id] = otherEntry
+			}
+		}
+		c.build()
+	}
+}
+
+func (c *CompositeCalculator) Finish() {
+	for _, entry := range c.entries {
+		entry.bucket.Finish()
+	}
+	c.build()
+}
+
+func (c *CompositeCalculator) build() {
+	c.trim()
+	c.buckets = c.buckets[:0]
+	c.keys = c.keys[:0]
+	for _, entry := range c.sorted {
+		c.buckets = append(c.buckets, entry.bucket)
+		c.keys = append(c.keys, entry.key)
+	}
+}
+
+// Buckets returns the buckets in key order
+func (c *CompositeCalculator) Buckets() []*search.Bucket {
+	return c.buckets
+}
+
+// Keys returns the keys of the buckets, in the order of Buckets
+func (c *CompositeCalculator) Keys() []CompositeKey {
+	return c.keys
+}
+
+// AfterKey returns the key of the last bucket, to request the buckets
+// following it, nil when there are no more buckets, as the page has
+// fewer than size buckets
+func (c *CompositeCalculator) AfterKey() CompositeKey {
+	if len(c.keys) == 0 || len(c.keys) < c.agg.size {
+		return nil
+	}
+	return c.keys[len(c.keys)-1]
+}
+
+// Err returns the error of the after key, if any
+func (c *CompositeCalculator) Err() error {
+	return c.agg.err
+}
@@ -0,0 +1,187 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+func TestComposite(t *testing.T) {
+	for _, shards := range []int{1, 2, 3} {
+		agg := Composite(10,
+			CompositeTerms("type", search.Field("type")),
+			CompositeHistogram("age", Histogram(search.Field("age"), 50, 0)))
+		calc := consumeTestDocs(t, agg, shards).(*CompositeCalculator)
+		expectNames := []string{"contractor|0", "employee|0", "employee|50"}
+		if !reflect.DeepEqual(bucketNames(calc.Buckets()), expectNames) {
+			t.Errorf("%d shards: expected buckets %v, got %v", shards, expectNames, bucketNames(calc.Buckets()))
+		}
+		expectCounts := map[string]uint64{"contractor|0": 2, "employee|0": 6, "employee|50": 2}
+		if !reflect.DeepEqual(bucketCounts(calc.Buckets()), expectCounts) {
+			t.Errorf("%d shards: expected counts %v, got %v", shards, expectCounts, bucketCounts(calc.Buckets()))
+		}
+		expectKeys := []CompositeKey{{"contractor", 0.0}, {"employee", 0.0}, {"employee", 50.0}}
+		if !reflect.DeepEqual(calc.Keys(), expectKeys) {
+			t.Errorf("%d shards: expected keys %v, got %v", shards, expectKeys, calc.Keys())
+		}
+	}
+}
+
+func TestCompositePaging(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources func() []*CompositeSource
+		pages   [][]string
+	}{
+		{
+			name: "ascending",
+			sources: func() []*CompositeSource {
+				return []*CompositeSource{
+					CompositeTerms("name", search.Field("name")),
+					CompositeTerms("type", search.Field("type")),
+				}
+			},
+			pages: [][]string{
+				{"barbara|employee", "carol|employee", "dale|employee"},
+				{"donna|employee", "gary|employee", "john|contractor"},
+				{"john|employee", "judy|contractor"},
+			},
+		},
+		{
+			name: "descending name",
+			sources: func() []*CompositeSource {
+				return []*CompositeSource{
+					CompositeTerms("name", search.Field("name")).Desc(),
+					CompositeTerms("type", search.Field("type")),
+				}
+			},
+			pages: [][]string{
+				{"judy|contractor", "john|contractor", "john|employee"},
+				{"gary|employee", "donna|employee", "dale|employee"},
+				{"carol|employee", "barbara|employee"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2, 3} {
+			var after CompositeKey
+			var pages [][]string
+			for {
+				agg := Composite(3, test.sources()...).After(after)
+				calc := consumeTestDocs(t, agg, shards).(*CompositeCalculator)
+				pages = append(pages, bucketNames(calc.Buckets()))
+				// the last page has fewer buckets, and no after key
+				after = calc.AfterKey()
+				if after == nil {
+					break
+				}
+			}
+			if !reflect.DeepEqual(pages, test.pages) {
+				t.Errorf("%s with %d shards: expected pages %v, got %v", test.name, shards, test.pages, pages)
+			}
+		}
+	}
+}
+
+func TestCompositeDateHistogram(t *testing.T) {
+	dates := make(testDates, 10)
+	for i := range dates {
+		month := time.January
+		if i >= 5 {
+			month = time.February
+		}
+		dates[i] = []time.Time{time.Date(2020, month, i+1, 12, 0, 0, 0, time.UTC)}
+	}
+	agg := Composite(4,
+		CompositeDateHistogram("month", DateHistogram(dates).CalendarInterval(CalendarMonth)),
+		CompositeTerms("type", search.Field("type")))
+	calc := consumeTestDocs(t, agg, 2).(*CompositeCalculator)
+	expect := map[string]uint64{
+		"2020-01-01T00:00:00Z|contractor": 1,
+		"2020-01-01T00:00:00Z|employee":   4,
+		"2020-02-01T00:00:00Z|contractor": 1,
+		"2020-02-01T00:00:00Z|employee":   4,
+	}
+	if !reflect.DeepEqual(bucketCounts(calc.Buckets()), expect) {
+		t.Errorf("expected counts %v, got %v", expect, bucketCounts(calc.Buckets()))
+	}
+	if !calc.AfterKey()[0].(time.Time).Equal(time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the after key to be in february, got %v", calc.AfterKey())
+	}
+}
+
+func TestCompositeMergeBound(t *testing.T) {
+	consume := func(calc search.Calculator, names ...string) {
+		for _, name := range names {
+			d := newDocumentMatch(0, 1, map[string][]byte{"name": []byte(name)})
+			err := d.LoadDocumentValues(search.NewSearchContext(0, 0), []string{"name"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			calc.Consume(d)
+		}
+	}
+
+	agg := Composite(2, CompositeTerms("name", search.Field("name")))
+	calc := agg.Calculator().(*CompositeCalculator)
+	consume(calc, "a")
+	calc.Finish()
+	other := agg.Calculator()
+	consume(other, "b")
+	other.Finish()
+
+	// once merged there are size keys, so no higher key can be kept
+	calc.Merge(other)
+	if !reflect.DeepEqual(calc.bound, CompositeKey{"b"}) {
+		t.Fatalf("expected bound [b], got %v", calc.bound)
+	}
+	consume(calc, "c", "a")
+	if len(calc.entries) != 2 {
+		t.Errorf("expected the key above the bound to be dropped, got %d entries", len(calc.entries))
+	}
+	calc.Finish()
+	if !reflect.DeepEqual(bucketNames(calc.Buckets()), []string{"a", "b"}) {
+		t.Errorf("expected buckets [a b], got %v", bucketNames(calc.Buckets()))
+	}
+}
+
+func TestCompositeAfterInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		after CompositeKey
+	}{
+		{"too few values", CompositeKey{"employee"}},
+		{"too many values", CompositeKey{"employee", 0.0, 1.0}},
+		{"wrong type", CompositeKey{"employee", "0"}},
+	}
+	for _, test := range tests {
+		agg := Composite(10,
+			CompositeTerms("type", search.Field("type")),
+			CompositeHistogram("age", Histogram(search.Field("age"), 50, 0))).
+			After(test.after)
+		calc := consumeTestDocs(t, agg, 2).(*CompositeCalculator)
+		if calc.Err() == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if len(calc.Buckets()) != 0 {
+			t.Errorf("%s: expected no buckets, got %v", test.name, bucketNames(calc.Buckets()))
+		}
+	}
+}
@@ -17,7 +17,7 @@ package aggregations
 import (
 	"time"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type DurationMetric struct{}
@@ -19,7 +19,7 @@ import (
 
 	"github.com/blugelabs/bluge/numeric/geo"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type FilteringTextSource struct {
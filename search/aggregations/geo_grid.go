@@ -0,0 +1,229 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/strivewrt/bluge/search"
+)
+
+const maxGeoHashPrecision = 12
+const maxGeoTileZoom = 29
+
+// web mercator tiles do not extend to the poles
+const maxGeoTileLat = 85.05112878
+
+// GeoGridAggregation groups geo points into the cells of a grid, the
+// buckets are named by cell and ordered by descending count
+type GeoGridAggregation struct {
+	src  search.GeoPointValuesSource
+	cell func(point *geo.Point) string
+	size int
+
+	bounded                                                bool
+	topLeftLon, topLeftLat, bottomRightLon, bottomRightLat float64
+
+	aggregations map[string]search.Aggregation
+}
+
+// GeoHashGrid buckets points by their geohash truncated to the
+// precision, between 1 and 12 characters
+func GeoHashGrid(src search.GeoPointValuesSource, precision int) *GeoGridAggregation {
+	if precision < 1 {
+		precision = 1
+	} else if precision > maxGeoHashPrecision {
+		precision = maxGeoHashPrecision
+	}
+	return newGeoGridAggregation(src, func(point *geo.Point) string {
+		return geo.EncodeGeoHash(point.Lat, point.Lon)[:precision]
+	})
+}
+
+// GeoTileGrid buckets points by the web mercator tile containing them
+// at the zoom level, between 0 and 29, named "zoom/x/y"
+func GeoTileGrid(src search.GeoPointValuesSource, zoom int) *GeoGridAggregation {
+	if zoom < 0 {
+		zoom = 0
+	} else if zoom > maxGeoTileZoom {
+		zoom = maxGeoTileZoom
+	}
+	prefix := strconv.Itoa(zoom) + "/"
+	return newGeoGridAggregation(src, func(point *geo.Point) string {
+		x, y := geoTile(point, zoom)
+		return prefix + strconv.Itoa(x) + "/" + strconv.Itoa(y)
+	})
+}
+
+func newGeoGridAggregation(src search.GeoPointValuesSource, cell func(point *geo.Point) string) *GeoGridAggregation {
+	return &GeoGridAggregation{
+		src:  src,
+		cell: cell,
+		size: 10000,
+		aggregations: map[string]search.Aggregation{
+			"count": CountMatches(),
+		},
+	}
+}
+
+// geoTile returns the x and y of the tile containing the point
+func geoTile(point *geo.Point, zoom int) (x, y int) {
+	tiles := 1 << uint(zoom)
+	lat := math.Max(-maxGeoTileLat, math.Min(maxGeoTileLat, point.Lat))
+	latRad := geo.DegreesToRadians(lat)
+	x = int(math.Floor((point.Lon + 180) / 360 * float64(tiles)))
+	y = int(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * float64(tiles)))
+	return clampTile(x, tiles), clampTile(y, tiles)
+}
+
+func clampTile(n, tiles int) int {
+	if n < 0 {
+		return 0
+	}
+	if n >= tiles {
+		return tiles - 1
+	}
+	return n
+}
+
+// Size limits the number of buckets returned, by default 10000
+func (a *GeoGridAggregation) Size(size int) *GeoGridAggregation {
+	a.size = size
+	return a
+}
+
+// BoundingBox only buckets the points within the box, which wraps
+// around the antimeridian when the top left longitude is greater than
+// the bottom right longitude
+func (a *GeoGridAggregation) BoundingBox(topLeftLon, topLeftLat, bottomRightLon, bottomRightLat float64) *GeoGridAggregation {
+	a.bounded = true
+	a.topLeftLon = topLeftLon
+	a.topLeftLat = topLeftLat
+	a.bottomRightLon = bottomRightLon
+	a.bottomRightLat = bottomRightLat
+	return a
+}
+
+func (a *GeoGridAggregation) AddAggregation(name string, agg search.Aggregation) *GeoGridAggregation {
+	a.aggregations[name] = agg
+	return a
+}
+
+func (a *GeoGridAggregation) Fields() []string {
+	rv := a.src.Fields()
+	for _, agg := range a.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (a *GeoGridAggregation) contains(point *geo.Point) bool {
+	if !a.bounded {
+		return true
+	}
+	if a.topLeftLon > a.bottomRightLon {
+		return geo.BoundingBoxContains(point.Lon, point.Lat, a.topLeftLon, a.bottomRightLat, 180, a.topLeftLat) ||
+			geo.BoundingBoxContains(point.Lon, point.Lat, -180, a.bottomRightLat, a.bottomRightLon, a.topLeftLat)
+	}
+	return geo.BoundingBoxContains(point.Lon, point.Lat, a.topLeftLon, a.bottomRightLat, a.bottomRightLon, a.topLeftLat)
+}
+
+func (a *GeoGridAggregation) Calculator() search.Calculator {
+	return &GeoGridCalculator{
+		agg:        a,
+		bucketsMap: make(map[string]*search.Bucket),
+	}
+}
+
+type GeoGridCalculator struct {
+	agg         *GeoGridAggregation
+	bucketsMap  map[string]*search.Bucket
+	bucketsList []*search.Bucket
+	cells       []string
+}
+
+func (g *GeoGridCalculator) Consume(d *search.DocumentMatch) {
+	g.cells = g.cells[:0]
+	for _, point := range g.agg.src.GeoPoints(d) {
+		if !g.agg.contains(point) {
+			continue
+		}
+		cell := g.agg.cell(point)
+		if containsString(g.cells, cell) {
+			continue
+		}
+		g.cells = append(g.cells, cell)
+		bucket, ok := g.bucketsMap[cell]
+		if !ok {
+			bucket = search.NewBucket(cell, g.agg.aggregations)
+			g.bucketsMap[cell] = bucket
+		}
+		bucket.Consume(d)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GeoGridCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoGridCalculator); ok {
+		for cell, otherBucket := range other.bucketsMap {
+			if bucket, ok := g.bucketsMap[cell]; ok {
+				bucket.Merge(otherBucket)
+			} else {
+				g.bucketsMap[cell] = otherBucket
+			}
+		}
+		g.build()
+	}
+}
+
+func (g *GeoGridCalculator) Finish() {
+	for _, bucket := range g.bucketsMap {
+		bucket.Finish()
+	}
+	g.build()
+}
+
+// build sorts and trims the list of buckets, the map keeps all of
+// them so that they can still be merged with those of other calculators
+func (g *GeoGridCalculator) build() {
+	g.bucketsList = g.bucketsList[:0]
+	for _, bucket := range g.bucketsMap {
+		g.bucketsList = append(g.bucketsList, bucket)
+	}
+	sort.Slice(g.bucketsList, func(i, j int) bool {
+		if g.bucketsList[i].Count() != g.bucketsList[j].Count() {
+			return g.bucketsList[i].Count() > g.bucketsList[j].Count()
+		}
+		return g.bucketsList[i].Name() < g.bucketsList[j].Name()
+	})
+	if g.agg.size >= 0 && len(g.bucketsList) > g.agg.size {
+		g.bucketsList = g.bucketsList[:g.agg.size]
+	}
+}
+
+func (g *GeoGridCalculator) Buckets() []*search.Bucket {
+	return g.bucketsList
+}
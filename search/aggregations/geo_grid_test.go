@@ -0,0 +1,135 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/strivewrt/bluge/search"
+)
+
+// testGeoPoints is a geo point source returning the points at the
+// document number
+type testGeoPoints [][]*geo.Point
+
+func (p testGeoPoints) Fields() []string {
+	return nil
+}
+
+func (p testGeoPoints) GeoPoints(match *search.DocumentMatch) []*geo.Point {
+	return p[match.Number]
+}
+
+var geoTestPoints = testGeoPoints{
+	{{Lon: -122.4194, Lat: 37.7749}},                                 // san francisco
+	{{Lon: -122.2711, Lat: 37.8044}, {Lon: -122.2712, Lat: 37.8045}}, // oakland, twice
+	{{Lon: -0.1276, Lat: 51.5072}},                                   // london
+	{{Lon: 178.4419, Lat: -18.1416}},                                 // suva
+	{{Lon: -179.9, Lat: -16.5}},                                      // across the antimeridian
+	nil,
+}
+
+// consumeGeoTestPoints runs the calculator over the test points, split
+// into shards which are merged
+func consumeGeoTestPoints(agg search.Aggregation, shards int) search.Calculator {
+	var merged search.Calculator
+	for shard := 0; shard < shards; shard++ {
+		calc := agg.Calculator()
+		for i := shard; i < len(geoTestPoints); i += shards {
+			calc.Consume(&search.DocumentMatch{Number: uint64(i)})
+		}
+		calc.Finish()
+		if merged == nil {
+			merged = calc
+		} else {
+			merged.Merge(calc)
+		}
+	}
+	return merged
+}
+
+func TestGeoGrid(t *testing.T) {
+	tests := []struct {
+		name   string
+		agg    func() search.Aggregation
+		names  []string
+		counts map[string]uint64
+	}{
+		{
+			name: "geohash",
+			agg: func() search.Aggregation {
+				return GeoHashGrid(geoTestPoints, 3)
+			},
+			names:  []string{"2j0", "9q8", "9q9", "gcp", "ruy"},
+			counts: map[string]uint64{"2j0": 1, "9q8": 1, "9q9": 1, "gcp": 1, "ruy": 1},
+		},
+		{
+			name: "geohash coarse",
+			agg: func() search.Aggregation {
+				return GeoHashGrid(geoTestPoints, 1)
+			},
+			names:  []string{"9", "2", "g", "r"},
+			counts: map[string]uint64{"9": 2, "2": 1, "g": 1, "r": 1},
+		},
+		{
+			name: "geohash size",
+			agg: func() search.Aggregation {
+				return GeoHashGrid(geoTestPoints, 1).Size(1)
+			},
+			names:  []string{"9"},
+			counts: map[string]uint64{"9": 2},
+		},
+		{
+			name: "geotile",
+			agg: func() search.Aggregation {
+				return GeoTileGrid(geoTestPoints, 1)
+			},
+			names:  []string{"1/0/0", "1/0/1", "1/1/1"},
+			counts: map[string]uint64{"1/0/0": 3, "1/0/1": 1, "1/1/1": 1},
+		},
+		{
+			name: "geotile bounding box",
+			agg: func() search.Aggregation {
+				return GeoTileGrid(geoTestPoints, 3).BoundingBox(-130, 40, -120, 30)
+			},
+			names:  []string{"3/1/3"},
+			counts: map[string]uint64{"3/1/3": 2},
+		},
+		{
+			name: "geohash bounding box across the antimeridian",
+			agg: func() search.Aggregation {
+				return GeoHashGrid(geoTestPoints, 1).BoundingBox(170, 0, -170, -30)
+			},
+			names:  []string{"2", "r"},
+			counts: map[string]uint64{"2": 1, "r": 1},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2, 3} {
+			buckets := consumeGeoTestPoints(test.agg(), shards).(*GeoGridCalculator).Buckets()
+			if !reflect.DeepEqual(bucketNames(buckets), test.names) {
+				t.Errorf("%s with %d shards: expected buckets %v, got %v",
+					test.name, shards, test.names, bucketNames(buckets))
+			}
+			if !reflect.DeepEqual(bucketCounts(buckets), test.counts) {
+				t.Errorf("%s with %d shards: expected counts %v, got %v",
+					test.name, shards, test.counts, bucketCounts(buckets))
+			}
+		}
+	}
+}
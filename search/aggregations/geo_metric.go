@@ -0,0 +1,189 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/strivewrt/bluge/search"
+)
+
+type GeoBoundsMetric struct {
+	src           search.GeoPointValuesSource
+	wrapLongitude bool
+}
+
+// GeoBounds computes the bounding box of the points, which may wrap
+// around the antimeridian when that gives a narrower box
+func GeoBounds(src search.GeoPointValuesSource) *GeoBoundsMetric {
+	return &GeoBoundsMetric{
+		src:           src,
+		wrapLongitude: true,
+	}
+}
+
+// WrapLongitude controls whether the bounds may wrap around the
+// antimeridian, by default they may
+func (g *GeoBoundsMetric) WrapLongitude(wrapLongitude bool) *GeoBoundsMetric {
+	g.wrapLongitude = wrapLongitude
+	return g
+}
+
+func (g *GeoBoundsMetric) Fields() []string {
+	return g.src.Fields()
+}
+
+func (g *GeoBoundsMetric) Calculator() search.Calculator {
+	return &GeoBoundsCalculator{
+		src:           g.src,
+		wrapLongitude: g.wrapLongitude,
+		top:           math.Inf(-1),
+		bottom:        math.Inf(1),
+		posLeft:       math.Inf(1),
+		posRight:      math.Inf(-1),
+		negLeft:       math.Inf(1),
+		negRight:      math.Inf(-1),
+	}
+}
+
+// GeoBoundsCalculator tracks the extent of the positive and negative
+// longitudes separately, so that the narrower of the box wrapping around
+// the antimeridian and the one which does not can be chosen
+type GeoBoundsCalculator struct {
+	src           search.GeoPointValuesSource
+	wrapLongitude bool
+
+	top, bottom       float64
+	posLeft, posRight float64
+	negLeft, negRight float64
+}
+
+func (g *GeoBoundsCalculator) Consume(d *search.DocumentMatch) {
+	for _, point := range g.src.GeoPoints(d) {
+		g.top = math.Max(g.top, point.Lat)
+		g.bottom = math.Min(g.bottom, point.Lat)
+		if point.Lon >= 0 {
+			g.posLeft = math.Min(g.posLeft, point.Lon)
+			g.posRight = math.Max(g.posRight, point.Lon)
+		} else {
+			g.negLeft = math.Min(g.negLeft, point.Lon)
+			g.negRight = math.Max(g.negRight, point.Lon)
+		}
+	}
+}
+
+func (g *GeoBoundsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoBoundsCalculator); ok {
+		g.top = math.Max(g.top, other.top)
+		g.bottom = math.Min(g.bottom, other.bottom)
+		g.posLeft = math.Min(g.posLeft, other.posLeft)
+		g.posRight = math.Max(g.posRight, other.posRight)
+		g.negLeft = math.Min(g.negLeft, other.negLeft)
+		g.negRight = math.Max(g.negRight, other.negRight)
+	}
+}
+
+func (g *GeoBoundsCalculator) Finish() {}
+
+func (g *GeoBoundsCalculator) Bounds() (topLeft, bottomRight *geo.Point) {
+	if math.IsInf(g.top, -1) {
+		return nil, nil
+	}
+	var left, right float64
+	switch {
+	case math.IsInf(g.posLeft, 1):
+		left, right = g.negLeft, g.negRight
+	case math.IsInf(g.negLeft, 1):
+		left, right = g.posLeft, g.posRight
+	default:
+		left, right = g.negLeft, g.posRight
+		if g.wrapLongitude {
+			unwrappedWidth := g.posRight - g.negLeft
+			wrappedWidth := (180 - g.posLeft) + (g.negRight + 180)
+			if wrappedWidth < unwrappedWidth {
+				left, right = g.posLeft, g.negRight
+			}
+		}
+	}
+	return &geo.Point{Lon: left, Lat: g.top}, &geo.Point{Lon: right, Lat: g.bottom}
+}
+
+type GeoCentroidMetric struct {
+	src search.GeoPointValuesSource
+}
+
+// GeoCentroid computes the centroid of the points on the sphere,
+// so points on either side of the antimeridian are centered on it
+func GeoCentroid(src search.GeoPointValuesSource) *GeoCentroidMetric {
+	return &GeoCentroidMetric{
+		src: src,
+	}
+}
+
+func (g *GeoCentroidMetric) Fields() []string {
+	return g.src.Fields()
+}
+
+func (g *GeoCentroidMetric) Calculator() search.Calculator {
+	return &GeoCentroidCalculator{
+		src: g.src,
+	}
+}
+
+// GeoCentroidCalculator sums the points as unit vectors, the direction
+// of the sum is the centroid
+type GeoCentroidCalculator struct {
+	src     search.GeoPointValuesSource
+	x, y, z float64
+	count   uint64
+}
+
+func (g *GeoCentroidCalculator) Consume(d *search.DocumentMatch) {
+	for _, point := range g.src.GeoPoints(d) {
+		lat := geo.DegreesToRadians(point.Lat)
+		lon := geo.DegreesToRadians(point.Lon)
+		g.x += math.Cos(lat) * math.Cos(lon)
+		g.y += math.Cos(lat) * math.Sin(lon)
+		g.z += math.Sin(lat)
+		g.count++
+	}
+}
+
+func (g *GeoCentroidCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoCentroidCalculator); ok {
+		g.x += other.x
+		g.y += other.y
+		g.z += other.z
+		g.count += other.count
+	}
+}
+
+func (g *GeoCentroidCalculator) Finish() {}
+
+// Count returns the number of points
+func (g *GeoCentroidCalculator) Count() uint64 {
+	return g.count
+}
+
+func (g *GeoCentroidCalculator) Centroid() *geo.Point {
+	if g.count == 0 {
+		return nil
+	}
+	return &geo.Point{
+		Lon: geo.RadiansToDegrees(math.Atan2(g.y, g.x)),
+		Lat: geo.RadiansToDegrees(math.Atan2(g.z, math.Hypot(g.x, g.y))),
+	}
+}
@@ -0,0 +1,168 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"testing"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/strivewrt/bluge/search"
+)
+
+func geoPointsClose(a, b *geo.Point) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(a.Lon-b.Lon) < 1e-6 && math.Abs(a.Lat-b.Lat) < 1e-6
+}
+
+func consumeGeoPoints(agg search.Aggregation, points testGeoPoints, shards int) search.Calculator {
+	var merged search.Calculator
+	for shard := 0; shard < shards; shard++ {
+		calc := agg.Calculator()
+		for i := shard; i < len(points); i += shards {
+			calc.Consume(&search.DocumentMatch{Number: uint64(i)})
+		}
+		calc.Finish()
+		if merged == nil {
+			merged = calc
+		} else {
+			merged.Merge(calc)
+		}
+	}
+	return merged
+}
+
+func TestGeoBounds(t *testing.T) {
+	pacific := testGeoPoints{
+		{{Lon: 178.4419, Lat: -18.1416}},
+		{{Lon: -179.9, Lat: -16.5}},
+		{{Lon: 170, Lat: -10}},
+	}
+	europe := testGeoPoints{
+		{{Lon: -0.1276, Lat: 51.5072}},
+		{{Lon: 2.3522, Lat: 48.8566}},
+	}
+	tests := []struct {
+		name        string
+		points      testGeoPoints
+		agg         *GeoBoundsMetric
+		topLeft     *geo.Point
+		bottomRight *geo.Point
+	}{
+		{
+			name:        "no points",
+			points:      testGeoPoints{nil},
+			agg:         GeoBounds(testGeoPoints{nil}),
+			topLeft:     nil,
+			bottomRight: nil,
+		},
+		{
+			name:        "negative longitudes",
+			points:      geoTestPoints[:3],
+			agg:         GeoBounds(geoTestPoints[:3]),
+			topLeft:     &geo.Point{Lon: -122.4194, Lat: 51.5072},
+			bottomRight: &geo.Point{Lon: -0.1276, Lat: 37.7749},
+		},
+		{
+			name:        "across the prime meridian",
+			points:      europe,
+			agg:         GeoBounds(europe),
+			topLeft:     &geo.Point{Lon: -0.1276, Lat: 51.5072},
+			bottomRight: &geo.Point{Lon: 2.3522, Lat: 48.8566},
+		},
+		{
+			name:        "narrower around the antimeridian",
+			points:      geoTestPoints,
+			agg:         GeoBounds(geoTestPoints),
+			topLeft:     &geo.Point{Lon: 178.4419, Lat: 51.5072},
+			bottomRight: &geo.Point{Lon: -0.1276, Lat: -18.1416},
+		},
+		{
+			name:        "wrapping around the antimeridian",
+			points:      pacific,
+			agg:         GeoBounds(pacific),
+			topLeft:     &geo.Point{Lon: 170, Lat: -10},
+			bottomRight: &geo.Point{Lon: -179.9, Lat: -18.1416},
+		},
+		{
+			name:        "wrapping disabled",
+			points:      pacific,
+			agg:         GeoBounds(pacific).WrapLongitude(false),
+			topLeft:     &geo.Point{Lon: -179.9, Lat: -10},
+			bottomRight: &geo.Point{Lon: 178.4419, Lat: -18.1416},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2} {
+			calc := consumeGeoPoints(test.agg, test.points, shards).(search.GeoBoundsCalculator)
+			topLeft, bottomRight := calc.Bounds()
+			if !geoPointsClose(topLeft, test.topLeft) || !geoPointsClose(bottomRight, test.bottomRight) {
+				t.Errorf("%s with %d shards: expected bounds %v %v, got %v %v", test.name, shards,
+					test.topLeft, test.bottomRight, topLeft, bottomRight)
+			}
+		}
+	}
+}
+
+func TestGeoCentroid(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   testGeoPoints
+		centroid *geo.Point
+	}{
+		{
+			name:     "no points",
+			points:   testGeoPoints{nil},
+			centroid: nil,
+		},
+		{
+			name: "single point",
+			points: testGeoPoints{
+				{{Lon: -122.4194, Lat: 37.7749}},
+			},
+			centroid: &geo.Point{Lon: -122.4194, Lat: 37.7749},
+		},
+		{
+			name: "across the antimeridian",
+			points: testGeoPoints{
+				{{Lon: 179, Lat: 10}},
+				{{Lon: -179, Lat: 10}},
+			},
+			centroid: &geo.Point{Lon: 180, Lat: 10.001493},
+		},
+		{
+			name: "on the equator",
+			points: testGeoPoints{
+				{{Lon: 10, Lat: 0}, {Lon: 20, Lat: 0}},
+				{{Lon: 30, Lat: 0}},
+			},
+			centroid: &geo.Point{Lon: 20, Lat: 0},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2} {
+			calc := consumeGeoPoints(GeoCentroid(test.points), test.points, shards)
+			centroid := calc.(search.GeoCentroidCalculator).Centroid()
+			if !geoPointsClose(centroid, test.centroid) {
+				t.Errorf("%s with %d shards: expected centroid %v, got %v", test.name, shards,
+					test.centroid, centroid)
+			}
+		}
+	}
+}
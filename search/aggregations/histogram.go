@@ -0,0 +1,326 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// HistogramAggregation groups numeric values into fixed width buckets,
+// the bucket of a value starts at offset + n*interval for some integer n.
+// The interval must be positive.
+type HistogramAggregation struct {
+	src          search.NumericValuesSource
+	interval     float64
+	offset       float64
+	minDocCount  uint64
+	bounds       *[2]float64
+	aggregations map[string]search.Aggregation
+}
+
+// Histogram buckets the values by the interval, it panics unless the
+// interval is positive and finite and the offset is finite
+func Histogram(src search.NumericValuesSource, interval, offset float64) *HistogramAggregation {
+	rv := &HistogramAggregation{
+		src:      src,
+		interval: interval,
+		offset:   offset,
+		aggregations: map[string]search.Aggregation{
+			"count": CountMatches(),
+		},
+	}
+	if !(interval > 0) || math.IsInf(interval, 0) {
+		panic(fmt.Errorf("histogram interval must be positive and finite, got %v", interval))
+	}
+	if math.IsNaN(offset) || math.IsInf(offset, 0) {
+		panic(fmt.Errorf("histogram offset must be finite, got %v", offset))
+	}
+	return rv
+}
+
+// MinDocCount omits buckets with fewer documents, by default empty
+// buckets between the lowest and highest bucket are included
+func (a *HistogramAggregation) MinDocCount(minDocCount uint64) *HistogramAggregation {
+	a.minDocCount = minDocCount
+	return a
+}
+
+// ExtendedBounds includes the empty buckets from min through max,
+// even if no documents have values in them, when MinDocCount is 0,
+// it panics unless min and max are finite
+func (a *HistogramAggregation) ExtendedBounds(min, max float64) *HistogramAggregation {
+	if math.IsNaN(min) || math.IsInf(min, 0) || math.IsNaN(max) || math.IsInf(max, 0) {
+		panic(fmt.Errorf("histogram extended bounds must be finite, got %v to %v", min, max))
+	}
+	a.bounds = &[2]float64{min, max}
+	return a
+}
+
+func (a *HistogramAggregation) AddAggregation(name string, agg search.Aggregation) *HistogramAggregation {
+	a.aggregations[name] = agg
+	return a
+}
+
+func (a *HistogramAggregation) Fields() []string {
+	rv := a.src.Fields()
+	for _, agg := range a.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (a *HistogramAggregation) Calculator() search.Calculator {
+	rv := &HistogramCalculator{
+		src:         a.src,
+		interval:    a.interval,
+		offset:      a.offset,
+		minDocCount: a.minDocCount,
+	}
+	rv.buckets = newHistogramBuckets(a.aggregations, rv.name, func(ordinal int64) int64 {
+		return ordinal + 1
+	})
+	if a.bounds != nil {
+		// bounds too far from the offset to be numbered are ignored,
+		// like the values there, as their buckets have no ordinal
+		lowest, lok := histogramOrdinal(a.bounds[0], a.interval, a.offset)
+		highest, hok := histogramOrdinal(a.bounds[1], a.interval, a.offset)
+		if lok && hok {
+			rv.bounds = &[2]int64{lowest, highest}
+		}
+	}
+	return rv
+}
+
+type HistogramCalculator struct {
+	src         search.NumericValuesSource
+	interval    float64
+	offset      float64
+	minDocCount uint64
+	bounds      *[2]int64
+	buckets     *histogramBuckets
+	ordinals    []int64
+	skipped     uint64
+}
+
+// histogramOrdinal is the number of intervals between the offset and the
+// bucket of the value, it is not ok when that number does not fit in an int64
+func histogramOrdinal(val, interval, offset float64) (int64, bool) {
+	ordinal := math.Floor((val - offset) / interval)
+	if !(ordinal >= math.MinInt64 && ordinal < math.MaxInt64) {
+		return 0, false
+	}
+	return int64(ordinal), true
+}
+
+// histogramKey is the lower bound of the bucket with the ordinal
+func histogramKey(ordinal int64, interval, offset float64) float64 {
+	return float64(ordinal)*interval + offset
+}
+
+func (h *HistogramCalculator) name(ordinal int64) string {
+	return strconv.FormatFloat(histogramKey(ordinal, h.interval, h.offset), 'f', -1, 64)
+}
+
+func (h *HistogramCalculator) Consume(d *search.DocumentMatch) {
+	h.ordinals = h.ordinals[:0]
+	for _, val := range h.src.Numbers(d) {
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			continue
+		}
+		ordinal, ok := histogramOrdinal(val, h.interval, h.offset)
+		if !ok {
+			h.skipped++
+			continue
+		}
+		h.ordinals = append(h.ordinals, ordinal)
+	}
+	h.buckets.consume(d, h.ordinals)
+}
+
+func (h *HistogramCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*HistogramCalculator); ok {
+		h.buckets.merge(other.buckets)
+		h.buckets.build(h.minDocCount, h.bounds)
+		h.skipped += other.skipped
+	}
+}
+
+func (h *HistogramCalculator) Finish() {
+	h.buckets.finish()
+	h.buckets.build(h.minDocCount, h.bounds)
+}
+
+func (h *HistogramCalculator) Buckets() []*search.Bucket {
+	return h.buckets.list
+}
+
+// Skipped returns the number of values which are in no bucket, as
+// their bucket is too far from the offset to be numbered
+func (h *HistogramCalculator) Skipped() uint64 {
+	return h.skipped
+}
+
+// Keys returns the lower bound of each bucket, in the order of Buckets
+func (h *HistogramCalculator) Keys() []float64 {
+	rv := make([]float64, len(h.buckets.ordinals))
+	for i, ordinal := range h.buckets.ordinals {
+		rv[i] = histogramKey(ordinal, h.interval, h.offset)
+	}
+	return rv
+}
+
+// MaxHistogramBuckets limits the number of buckets spanned by a histogram
+// when empty buckets are included, so that a small interval over a wide
+// range of values cannot exhaust memory
+const MaxHistogramBuckets = 65536
+
+// histogramBuckets holds the buckets of a histogram keyed by an ordinal
+// which orders them, next returns the ordinal of the following bucket
+type histogramBuckets struct {
+	aggregations map[string]search.Aggregation
+	name         func(int64) string
+	next         func(int64) int64
+
+	buckets map[int64]*search.Bucket
+
+	// built from buckets by build
+	ordinals []int64
+	list     []*search.Bucket
+}
+
+func newHistogramBuckets(aggregations map[string]search.Aggregation,
+	name func(int64) string, next func(int64) int64) *histogramBuckets {
+	return &histogramBuckets{
+		aggregations: aggregations,
+		name:         name,
+		next:         next,
+		buckets:      make(map[int64]*search.Bucket),
+	}
+}
+
+// consume adds the document to the bucket of each ordinal, once
+func (h *histogramBuckets) consume(d *search.DocumentMatch, ordinals []int64) {
+	for i, ordinal := range ordinals {
+		var seen bool
+		for _, prev := range ordinals[:i] {
+			if prev == ordinal {
+				seen = true
+				break
+			}
+		}
+		if seen {
+			continue
+		}
+		bucket, ok := h.buckets[ordinal]
+		if !ok {
+			bucket = search.NewBucket(h.name(ordinal), h.aggregations)
+			h.buckets[ordinal] = bucket
+		}
+		bucket.Consume(d)
+	}
+}
+
+func (h *histogramBuckets) merge(other *histogramBuckets) {
+	for ordinal, otherBucket := range other.buckets {
+		if bucket, ok := h.buckets[ordinal]; ok {
+			bucket.Merge(otherBucket)
+		} else {
+			h.buckets[ordinal] = otherBucket
+		}
+	}
+}
+
+func (h *histogramBuckets) finish() {
+	for _, bucket := range h.buckets {
+		bucket.Finish()
+	}
+}
+
+// build orders the buckets, adding empty buckets between the lowest and
+// highest ordinals and within the bounds when minDocCount is 0, or omitting
+// the buckets with fewer documents otherwise.  All buckets are kept, so
+// that they can still be merged with those of other calculators.  No empty
+// buckets are added when that would span more than MaxHistogramBuckets.
+func (h *histogramBuckets) build(minDocCount uint64, bounds *[2]int64) {
+	if minDocCount == 0 && (len(h.buckets) > 0 || bounds != nil) {
+		var lowest, highest int64
+		first := true
+		for ordinal := range h.buckets {
+			if first || ordinal < lowest {
+				lowest = ordinal
+			}
+			if first || ordinal > highest {
+				highest = ordinal
+			}
+			first = false
+		}
+		if bounds != nil {
+			if first || bounds[0] < lowest {
+				lowest = bounds[0]
+			}
+			if first || bounds[1] > highest {
+				highest = bounds[1]
+			}
+		}
+		if h.span(lowest, highest) <= MaxHistogramBuckets {
+			for ordinal := lowest; ordinal <= highest; {
+				if _, ok := h.buckets[ordinal]; !ok {
+					bucket := search.NewBucket(h.name(ordinal), h.aggregations)
+					bucket.Finish()
+					h.buckets[ordinal] = bucket
+				}
+				next := h.next(ordinal)
+				if next <= ordinal {
+					break
+				}
+				ordinal = next
+			}
+		}
+	}
+
+	h.ordinals = h.ordinals[:0]
+	for ordinal, bucket := range h.buckets {
+		if bucket.Count() >= minDocCount {
+			h.ordinals = append(h.ordinals, ordinal)
+		}
+	}
+	sort.Slice(h.ordinals, func(i, j int) bool {
+		return h.ordinals[i] < h.ordinals[j]
+	})
+	h.list = h.list[:0]
+	for _, ordinal := range h.ordinals {
+		h.list = append(h.list, h.buckets[ordinal])
+	}
+}
+
+// span counts the buckets from lowest through highest, stopping once
+// there are more than MaxHistogramBuckets
+func (h *histogramBuckets) span(lowest, highest int64) int {
+	var rv int
+	for ordinal := lowest; ordinal <= highest && rv <= MaxHistogramBuckets; rv++ {
+		next := h.next(ordinal)
+		if next <= ordinal {
+			// the following bucket does not fit in an int64
+			return rv + 1
+		}
+		ordinal = next
+	}
+	return rv
+}
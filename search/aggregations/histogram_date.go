@@ -0,0 +1,283 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// CalendarUnit is a calendar aware interval, its duration varies with
+// daylight saving time, month lengths and leap years
+type CalendarUnit int
+
+const (
+	CalendarMinute CalendarUnit = iota
+	CalendarHour
+	CalendarDay
+	// CalendarWeek buckets start on Monday
+	CalendarWeek
+	CalendarMonth
+	CalendarQuarter
+	CalendarYear
+)
+
+// DateHistogramAggregation groups dates into buckets of either a fixed
+// duration or a calendar unit.  Calendar buckets start on the boundaries
+// of the unit in the location, shifted by the offset.  Fixed buckets are
+// multiples of the duration since the Unix epoch, shifted by the offset,
+// the location only affects their names.
+type DateHistogramAggregation struct {
+	src          search.DateValuesSource
+	calendar     CalendarUnit
+	fixed        time.Duration
+	location     *time.Location
+	offset       time.Duration
+	minDocCount  uint64
+	bounds       *[2]time.Time
+	aggregations map[string]search.Aggregation
+}
+
+// DateHistogram buckets by calendar day in UTC, unless configured otherwise
+func DateHistogram(src search.DateValuesSource) *DateHistogramAggregation {
+	return &DateHistogramAggregation{
+		src:      src,
+		calendar: CalendarDay,
+		location: time.UTC,
+		aggregations: map[string]search.Aggregation{
+			"count": CountMatches(),
+		},
+	}
+}
+
+func (a *DateHistogramAggregation) CalendarInterval(unit CalendarUnit) *DateHistogramAggregation {
+	a.calendar = unit
+	a.fixed = 0
+	return a
+}
+
+// FixedInterval uses buckets of the duration, it panics
+// unless the duration is positive
+func (a *DateHistogramAggregation) FixedInterval(interval time.Duration) *DateHistogramAggregation {
+	if interval <= 0 {
+		panic(fmt.Errorf("date histogram interval must be positive, got %v", interval))
+	}
+	a.fixed = interval
+	return a
+}
+
+// Location sets the location of the calendar units and bucket names,
+// nil is treated as UTC
+func (a *DateHistogramAggregation) Location(location *time.Location) *DateHistogramAggregation {
+	if location == nil {
+		location = time.UTC
+	}
+	a.location = location
+	return a
+}
+
+// Offset shifts the start of each bucket, for example an offset of 6
+// hours with a calendar day interval starts buckets at 6am
+func (a *DateHistogramAggregation) Offset(offset time.Duration) *DateHistogramAggregation {
+	a.offset = offset
+	return a
+}
+
+// MinDocCount omits buckets with fewer documents, by default empty
+// buckets between the lowest and highest bucket are included
+func (a *DateHistogramAggregation) MinDocCount(minDocCount uint64) *DateHistogramAggregation {
+	a.minDocCount = minDocCount
+	return a
+}
+
+// ExtendedBounds includes the empty buckets from min through max,
+// even if no documents have values in them, when MinDocCount is 0
+func (a *DateHistogramAggregation) ExtendedBounds(min, max time.Time) *DateHistogramAggregation {
+	a.bounds = &[2]time.Time{min, max}
+	return a
+}
+
+func (a *DateHistogramAggregation) AddAggregation(name string, agg search.Aggregation) *DateHistogramAggregation {
+	a.aggregations[name] = agg
+	return a
+}
+
+func (a *DateHistogramAggregation) Fields() []string {
+	rv := a.src.Fields()
+	for _, agg := range a.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (a *DateHistogramAggregation) rounding() dateRounding {
+	return dateRounding{
+		calendar: a.calendar,
+		fixed:    a.fixed,
+		location: a.location,
+		offset:   a.offset,
+	}
+}
+
+func (a *DateHistogramAggregation) Calculator() search.Calculator {
+	rv := &DateHistogramCalculator{
+		dateRounding: a.rounding(),
+		src:          a.src,
+		minDocCount:  a.minDocCount,
+	}
+	rv.buckets = newHistogramBuckets(a.aggregations, rv.name, rv.next)
+	if a.bounds != nil {
+		rv.bounds = &[2]int64{rv.ordinal(a.bounds[0]), rv.ordinal(a.bounds[1])}
+	}
+	return rv
+}
+
+// dateRounding places dates in the buckets of a date histogram, which are
+// identified by the Unix nanoseconds of their start
+type dateRounding struct {
+	calendar CalendarUnit
+	fixed    time.Duration
+	location *time.Location
+	offset   time.Duration
+}
+
+// DateHistogramCalculator identifies buckets by the Unix nanoseconds
+// of their start
+type DateHistogramCalculator struct {
+	dateRounding
+	src         search.DateValuesSource
+	minDocCount uint64
+	bounds      *[2]int64
+	buckets     *histogramBuckets
+	ordinals    []int64
+}
+
+func (h dateRounding) ordinal(t time.Time) int64 {
+	if h.fixed > 0 {
+		return floorMultiple(t.UnixNano()-int64(h.offset), int64(h.fixed)) + int64(h.offset)
+	}
+	return h.floor(t.Add(-h.offset)).Add(h.offset).UnixNano()
+}
+
+func (h dateRounding) next(ordinal int64) int64 {
+	if h.fixed > 0 {
+		return ordinal + int64(h.fixed)
+	}
+	start := h.floor(h.start(ordinal).Add(-h.offset))
+	var next time.Time
+	switch h.calendar {
+	case CalendarMinute:
+		next = h.floor(start.Add(time.Minute))
+	case CalendarHour:
+		next = h.floor(start.Add(time.Hour))
+	case CalendarDay:
+		next = h.floor(start.AddDate(0, 0, 1))
+	case CalendarWeek:
+		next = h.floor(start.AddDate(0, 0, 7))
+	case CalendarMonth:
+		next = h.floor(start.AddDate(0, 1, 0))
+	case CalendarQuarter:
+		next = h.floor(start.AddDate(0, 3, 0))
+	default:
+		next = h.floor(start.AddDate(1, 0, 0))
+	}
+	return next.Add(h.offset).UnixNano()
+}
+
+// floor returns the start of the calendar unit containing t
+func (h dateRounding) floor(t time.Time) time.Time {
+	t = t.In(h.location)
+	switch h.calendar {
+	case CalendarMinute:
+		return floorWallClock(t, time.Minute)
+	case CalendarHour:
+		return floorWallClock(t, time.Hour)
+	}
+	year, month, day := t.Date()
+	switch h.calendar {
+	case CalendarDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, h.location)
+	case CalendarWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, h.location)
+	case CalendarMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, h.location)
+	case CalendarQuarter:
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, h.location)
+	default:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, h.location)
+	}
+}
+
+// floorWallClock truncates the wall clock time, using the zone offset in
+// effect at t so that repeated wall clock times stay distinct
+func floorWallClock(t time.Time, unit time.Duration) time.Time {
+	_, zoneOffset := t.Zone()
+	wall := t.UnixNano() + int64(zoneOffset)*int64(time.Second)
+	wall = floorMultiple(wall, int64(unit))
+	return time.Unix(0, wall-int64(zoneOffset)*int64(time.Second)).In(t.Location())
+}
+
+// floorMultiple returns the greatest multiple of m less than or equal to n
+func floorMultiple(n, m int64) int64 {
+	rv := n - n%m
+	if n%m < 0 {
+		rv -= m
+	}
+	return rv
+}
+
+func (h dateRounding) start(ordinal int64) time.Time {
+	return time.Unix(0, ordinal).In(h.location)
+}
+
+func (h *DateHistogramCalculator) name(ordinal int64) string {
+	return h.start(ordinal).Format(time.RFC3339)
+}
+
+func (h *DateHistogramCalculator) Consume(d *search.DocumentMatch) {
+	h.ordinals = h.ordinals[:0]
+	for _, val := range h.src.Dates(d) {
+		h.ordinals = append(h.ordinals, h.ordinal(val))
+	}
+	h.buckets.consume(d, h.ordinals)
+}
+
+func (h *DateHistogramCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*DateHistogramCalculator); ok {
+		h.buckets.merge(other.buckets)
+		h.buckets.build(h.minDocCount, h.bounds)
+	}
+}
+
+func (h *DateHistogramCalculator) Finish() {
+	h.buckets.finish()
+	h.buckets.build(h.minDocCount, h.bounds)
+}
+
+func (h *DateHistogramCalculator) Buckets() []*search.Bucket {
+	return h.buckets.list
+}
+
+// Keys returns the start of each bucket, in the order of Buckets
+func (h *DateHistogramCalculator) Keys() []time.Time {
+	rv := make([]time.Time, len(h.buckets.ordinals))
+	for i, ordinal := range h.buckets.ordinals {
+		rv[i] = h.start(ordinal)
+	}
+	return rv
+}
@@ -0,0 +1,300 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// testDates is a date source returning the dates at the document number
+type testDates [][]time.Time
+
+func (d testDates) Fields() []string {
+	return nil
+}
+
+func (d testDates) Dates(match *search.DocumentMatch) []time.Time {
+	return d[match.Number]
+}
+
+func TestDateHistogram(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	utc := func(value string) time.Time {
+		rv, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rv
+	}
+
+	tests := []struct {
+		name   string
+		agg    func(src search.DateValuesSource) *DateHistogramAggregation
+		dates  testDates
+		names  []string
+		counts map[string]uint64
+	}{
+		{
+			name: "hour repeated when daylight saving time ends",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).CalendarInterval(CalendarHour).Location(newYork)
+			},
+			dates: testDates{
+				{utc("2020-11-01T05:30:00Z")},
+				{utc("2020-11-01T06:30:00Z")},
+				{utc("2020-11-01T06:45:00Z")},
+			},
+			names: []string{"2020-11-01T01:00:00-04:00", "2020-11-01T01:00:00-05:00"},
+			counts: map[string]uint64{
+				"2020-11-01T01:00:00-04:00": 1,
+				"2020-11-01T01:00:00-05:00": 2,
+			},
+		},
+		{
+			name: "hour skipped when daylight saving time starts",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).CalendarInterval(CalendarHour).Location(newYork)
+			},
+			dates: testDates{
+				{utc("2020-03-08T06:30:00Z")},
+				{utc("2020-03-08T07:30:00Z")},
+			},
+			names: []string{"2020-03-08T01:00:00-05:00", "2020-03-08T03:00:00-04:00"},
+			counts: map[string]uint64{
+				"2020-03-08T01:00:00-05:00": 1,
+				"2020-03-08T03:00:00-04:00": 1,
+			},
+		},
+		{
+			name: "days around daylight saving time",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).Location(newYork)
+			},
+			dates: testDates{
+				{utc("2020-03-08T04:30:00Z")},
+				{utc("2020-03-10T03:30:00Z")},
+			},
+			names: []string{"2020-03-07T00:00:00-05:00", "2020-03-08T00:00:00-05:00",
+				"2020-03-09T00:00:00-04:00"},
+			counts: map[string]uint64{
+				"2020-03-07T00:00:00-05:00": 1,
+				"2020-03-08T00:00:00-05:00": 0,
+				"2020-03-09T00:00:00-04:00": 1,
+			},
+		},
+		{
+			name: "day with offset",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).Offset(6 * time.Hour)
+			},
+			dates: testDates{
+				{utc("2020-01-02T05:00:00Z")},
+				{utc("2020-01-02T07:00:00Z")},
+			},
+			names: []string{"2020-01-01T06:00:00Z", "2020-01-02T06:00:00Z"},
+			counts: map[string]uint64{
+				"2020-01-01T06:00:00Z": 1,
+				"2020-01-02T06:00:00Z": 1,
+			},
+		},
+		{
+			name: "weeks start on monday",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).CalendarInterval(CalendarWeek)
+			},
+			dates: testDates{
+				{utc("2020-01-01T12:00:00Z"), utc("2020-01-05T23:59:59Z")},
+				{utc("2020-01-06T00:00:00Z")},
+			},
+			names: []string{"2019-12-30T00:00:00Z", "2020-01-06T00:00:00Z"},
+			counts: map[string]uint64{
+				"2019-12-30T00:00:00Z": 1,
+				"2020-01-06T00:00:00Z": 1,
+			},
+		},
+		{
+			name: "months filled between values",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).CalendarInterval(CalendarMonth).Location(newYork)
+			},
+			dates: testDates{
+				{utc("2020-01-31T12:00:00Z")},
+				{utc("2020-04-01T03:00:00Z")},
+			},
+			names: []string{"2020-01-01T00:00:00-05:00", "2020-02-01T00:00:00-05:00",
+				"2020-03-01T00:00:00-05:00"},
+			counts: map[string]uint64{
+				"2020-01-01T00:00:00-05:00": 1,
+				"2020-02-01T00:00:00-05:00": 0,
+				"2020-03-01T00:00:00-05:00": 1,
+			},
+		},
+		{
+			name: "quarters with extended bounds",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).CalendarInterval(CalendarQuarter).
+					ExtendedBounds(utc("2020-01-01T00:00:00Z"), utc("2020-12-31T00:00:00Z"))
+			},
+			dates: testDates{
+				{utc("2020-05-15T00:00:00Z")},
+			},
+			names: []string{"2020-01-01T00:00:00Z", "2020-04-01T00:00:00Z",
+				"2020-07-01T00:00:00Z", "2020-10-01T00:00:00Z"},
+			counts: map[string]uint64{
+				"2020-01-01T00:00:00Z": 0,
+				"2020-04-01T00:00:00Z": 1,
+				"2020-07-01T00:00:00Z": 0,
+				"2020-10-01T00:00:00Z": 0,
+			},
+		},
+		{
+			name: "years with min doc count",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				return DateHistogram(src).CalendarInterval(CalendarYear).MinDocCount(1)
+			},
+			dates: testDates{
+				{utc("2018-05-15T00:00:00Z")},
+				{utc("2020-05-15T00:00:00Z")},
+			},
+			names: []string{"2018-01-01T00:00:00Z", "2020-01-01T00:00:00Z"},
+			counts: map[string]uint64{
+				"2018-01-01T00:00:00Z": 1,
+				"2020-01-01T00:00:00Z": 1,
+			},
+		},
+		{
+			name: "fixed interval",
+			agg: func(src search.DateValuesSource) *DateHistogramAggregation {
+				// the empty buckets between 1969 and 2020 are not of interest
+				return DateHistogram(src).FixedInterval(90 * time.Minute).MinDocCount(1)
+			},
+			dates: testDates{
+				{utc("2020-01-01T00:10:00Z")},
+				{utc("2020-01-01T01:40:00Z")},
+				{utc("1969-12-31T23:00:00Z")},
+			},
+			names: []string{"1969-12-31T22:30:00Z", "2020-01-01T00:00:00Z", "2020-01-01T01:30:00Z"},
+			counts: map[string]uint64{
+				"1969-12-31T22:30:00Z": 1,
+				"2020-01-01T00:00:00Z": 1,
+				"2020-01-01T01:30:00Z": 1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2} {
+			var merged search.Calculator
+			for shard := 0; shard < shards; shard++ {
+				calc := test.agg(test.dates).Calculator()
+				for i := shard; i < len(test.dates); i += shards {
+					calc.Consume(&search.DocumentMatch{Number: uint64(i)})
+				}
+				calc.Finish()
+				if merged == nil {
+					merged = calc
+				} else {
+					merged.Merge(calc)
+				}
+			}
+			buckets := merged.(*DateHistogramCalculator).Buckets()
+			if !reflect.DeepEqual(bucketNames(buckets), test.names) {
+				t.Errorf("%s with %d shards: expected buckets %v, got %v",
+					test.name, shards, test.names, bucketNames(buckets))
+			}
+			if !reflect.DeepEqual(bucketCounts(buckets), test.counts) {
+				t.Errorf("%s with %d shards: expected counts %v, got %v",
+					test.name, shards, test.counts, bucketCounts(buckets))
+			}
+		}
+	}
+}
+
+func TestDateHistogramKeys(t *testing.T) {
+	dates := testDates{
+		{time.Date(2020, 2, 10, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	calc := DateHistogram(dates).CalendarInterval(CalendarMonth).
+		Calculator().(*DateHistogramCalculator)
+	for i := range dates {
+		calc.Consume(&search.DocumentMatch{Number: uint64(i)})
+	}
+	calc.Finish()
+	expect := []time.Time{
+		time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(calc.Keys(), expect) {
+		t.Errorf("expected keys %v, got %v", expect, calc.Keys())
+	}
+	if calc.Buckets()[0].Count() != 2 || calc.Buckets()[1].Count() != 0 {
+		t.Errorf("expected counts 2 and 0, got %d and %d",
+			calc.Buckets()[0].Count(), calc.Buckets()[1].Count())
+	}
+}
+
+func TestDateHistogramLimits(t *testing.T) {
+	dates := testDates{
+		{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, interval := range []time.Duration{0, -time.Hour} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("interval %v: expected a panic", interval)
+				}
+			}()
+			DateHistogram(dates).FixedInterval(interval)
+		}()
+	}
+
+	// no empty buckets are added beyond the limit
+	for _, agg := range []*DateHistogramAggregation{
+		DateHistogram(dates).FixedInterval(time.Nanosecond),
+		DateHistogram(dates).CalendarInterval(CalendarMinute).ExtendedBounds(
+			time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)),
+	} {
+		calc := agg.Calculator().(*DateHistogramCalculator)
+		for i := range dates {
+			calc.Consume(&search.DocumentMatch{Number: uint64(i)})
+		}
+		calc.Finish()
+		if len(calc.Buckets()) != len(dates) {
+			t.Errorf("expected %d buckets, got %d", len(dates), len(calc.Buckets()))
+		}
+	}
+
+	// a nil location is treated as UTC
+	calc := DateHistogram(dates).CalendarInterval(CalendarYear).Location(nil).
+		Calculator().(*DateHistogramCalculator)
+	for i := range dates {
+		calc.Consume(&search.DocumentMatch{Number: uint64(i)})
+	}
+	calc.Finish()
+	if !reflect.DeepEqual(bucketNames(calc.Buckets()), []string{"2020-01-01T00:00:00Z"}) {
+		t.Errorf("expected a 2020 bucket in UTC, got %v", bucketNames(calc.Buckets()))
+	}
+}
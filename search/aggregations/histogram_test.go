@@ -0,0 +1,234 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// consumeTestDocs runs the aggregation over the test docs, split into
+// shards which are merged, like a multi search does
+func consumeTestDocs(t *testing.T, agg search.Aggregation, shards int) search.Calculator {
+	return consumeTestDocsBucket(t, search.Aggregations{"agg": agg}, shards).Aggregation("agg")
+}
+
+func consumeTestDocsBucket(t *testing.T, aggs search.Aggregations, shards int) *search.Bucket {
+	testDocs := buildTestDocs()
+	var merged *search.Bucket
+	for shard := 0; shard < shards; shard++ {
+		bucket := search.NewBucket("shard", aggs)
+		for i := shard; i < len(testDocs); i += shards {
+			err := testDocs[i].LoadDocumentValues(search.NewSearchContext(0, 0), aggs.Fields())
+			if err != nil {
+				t.Fatal(err)
+			}
+			bucket.Consume(testDocs[i])
+		}
+		bucket.Finish()
+		if merged == nil {
+			merged = bucket
+		} else {
+			merged.Merge(bucket)
+		}
+	}
+	return merged
+}
+
+func bucketCounts(buckets []*search.Bucket) map[string]uint64 {
+	rv := make(map[string]uint64, len(buckets))
+	for _, bucket := range buckets {
+		rv[bucket.Name()] = bucket.Count()
+	}
+	return rv
+}
+
+func bucketNames(buckets []*search.Bucket) []string {
+	rv := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		rv[i] = bucket.Name()
+	}
+	return rv
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		agg    func() search.Aggregation
+		names  []string
+		counts map[string]uint64
+	}{
+		{
+			name: "interval",
+			agg: func() search.Aggregation {
+				return Histogram(search.Field("age"), 20, 0)
+			},
+			names:  []string{"0", "20", "40", "60", "80"},
+			counts: map[string]uint64{"0": 4, "20": 3, "40": 1, "60": 1, "80": 1},
+		},
+		{
+			name: "offset fills empty buckets",
+			agg: func() search.Aggregation {
+				return Histogram(search.Field("age"), 10, 5)
+			},
+			names: []string{"-5", "5", "15", "25", "35", "45", "55", "65", "75", "85", "95"},
+			counts: map[string]uint64{"-5": 2, "5": 1, "15": 1, "25": 2, "35": 1,
+				"45": 1, "55": 1, "65": 0, "75": 0, "85": 0, "95": 1},
+		},
+		{
+			name: "extended bounds",
+			agg: func() search.Aggregation {
+				return Histogram(search.Field("age"), 20, 0).ExtendedBounds(-20, 120)
+			},
+			names: []string{"-20", "0", "20", "40", "60", "80", "100", "120"},
+			counts: map[string]uint64{"-20": 0, "0": 4, "20": 3, "40": 1, "60": 1,
+				"80": 1, "100": 0, "120": 0},
+		},
+		{
+			name: "min doc count",
+			agg: func() search.Aggregation {
+				return Histogram(search.Field("age"), 20, 0).MinDocCount(2)
+			},
+			names:  []string{"0", "20"},
+			counts: map[string]uint64{"0": 4, "20": 3},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2, 3} {
+			calc := consumeTestDocs(t, test.agg(), shards).(search.BucketCalculator)
+			if !reflect.DeepEqual(bucketNames(calc.Buckets()), test.names) {
+				t.Errorf("%s with %d shards: expected buckets %v, got %v",
+					test.name, shards, test.names, bucketNames(calc.Buckets()))
+			}
+			if !reflect.DeepEqual(bucketCounts(calc.Buckets()), test.counts) {
+				t.Errorf("%s with %d shards: expected counts %v, got %v",
+					test.name, shards, test.counts, bucketCounts(calc.Buckets()))
+			}
+		}
+	}
+}
+
+func TestHistogramSubAggregation(t *testing.T) {
+	agg := Histogram(search.Field("age"), 50, 0).
+		AddAggregation("max_age", Max(search.Field("age")))
+	calc := consumeTestDocs(t, agg, 2).(*HistogramCalculator)
+	if !reflect.DeepEqual(calc.Keys(), []float64{0, 50}) {
+		t.Fatalf("expected keys [0 50], got %v", calc.Keys())
+	}
+	buckets := calc.Buckets()
+	if buckets[0].Metric("max_age") != 48 || buckets[1].Metric("max_age") != 95 {
+		t.Errorf("expected max ages 48 and 95, got %f and %f",
+			buckets[0].Metric("max_age"), buckets[1].Metric("max_age"))
+	}
+}
+
+func TestHistogramInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func()
+	}{
+		{
+			name: "zero interval",
+			build: func() {
+				Histogram(search.Field("age"), 0, 0)
+			},
+		},
+		{
+			name: "negative interval",
+			build: func() {
+				Histogram(search.Field("age"), -10, 0)
+			},
+		},
+		{
+			name: "nan interval",
+			build: func() {
+				Histogram(search.Field("age"), math.NaN(), 0)
+			},
+		},
+		{
+			name: "infinite interval",
+			build: func() {
+				Histogram(search.Field("age"), math.Inf(1), 0)
+			},
+		},
+		{
+			name: "infinite offset",
+			build: func() {
+				Histogram(search.Field("age"), 10, math.Inf(-1))
+			},
+		},
+		{
+			name: "nan bounds",
+			build: func() {
+				Histogram(search.Field("age"), 10, 0).ExtendedBounds(math.NaN(), 100)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.name)
+				}
+			}()
+			test.build()
+		}()
+	}
+}
+
+func TestHistogramLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		agg     *HistogramAggregation
+		buckets int
+		skipped uint64
+	}{
+		{
+			name:    "too many buckets",
+			agg:     Histogram(search.Field("age"), 0.0001, 0),
+			buckets: 10,
+		},
+		{
+			name:    "too many buckets in bounds",
+			agg:     Histogram(search.Field("age"), 1, 0).ExtendedBounds(-1e18, 1e18),
+			buckets: 10,
+		},
+		{
+			name:    "ordinals out of range",
+			agg:     Histogram(search.Field("age"), 1e-300, 0),
+			skipped: 10,
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2} {
+			calc := consumeTestDocs(t, test.agg, shards).(*HistogramCalculator)
+			// no empty buckets are added beyond the limit
+			if len(calc.Buckets()) != test.buckets {
+				t.Errorf("%s with %d shards: expected %d buckets, got %d",
+					test.name, shards, test.buckets, len(calc.Buckets()))
+			}
+			if calc.Skipped() != test.skipped {
+				t.Errorf("%s with %d shards: expected %d values skipped, got %d",
+					test.name, shards, test.skipped, calc.Skipped())
+			}
+		}
+	}
+}
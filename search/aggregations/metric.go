@@ -17,7 +17,7 @@ package aggregations
 import (
 	"math"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type SingleValueMetric struct {
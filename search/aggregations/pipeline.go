@@ -0,0 +1,516 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// PipelineAggregation computes over the finished buckets of a bucket
+// aggregation, adding metrics to them or selecting and ordering them.
+// It returns the buckets to keep.
+type PipelineAggregation interface {
+	Process(buckets []*search.Bucket) []*search.Bucket
+}
+
+// PipelinesAggregation runs pipeline aggregations, in order, over the
+// buckets of a bucket aggregation once they are finished, and again each
+// time they are merged
+type PipelinesAggregation struct {
+	agg       search.Aggregation
+	pipelines []PipelineAggregation
+}
+
+func WithPipelines(agg search.Aggregation, pipelines ...PipelineAggregation) *PipelinesAggregation {
+	return &PipelinesAggregation{
+		agg:       agg,
+		pipelines: pipelines,
+	}
+}
+
+func (a *PipelinesAggregation) AddPipeline(pipeline PipelineAggregation) *PipelinesAggregation {
+	a.pipelines = append(a.pipelines, pipeline)
+	return a
+}
+
+func (a *PipelinesAggregation) Fields() []string {
+	return a.agg.Fields()
+}
+
+func (a *PipelinesAggregation) Calculator() search.Calculator {
+	return &PipelinesCalculator{
+		calculator: a.agg.Calculator(),
+		pipelines:  a.pipelines,
+	}
+}
+
+type PipelinesCalculator struct {
+	calculator search.Calculator
+	pipelines  []PipelineAggregation
+	buckets    []*search.Bucket
+}
+
+func (p *PipelinesCalculator) Consume(d *search.DocumentMatch) {
+	p.calculator.Consume(d)
+}
+
+func (p *PipelinesCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*PipelinesCalculator); ok {
+		p.calculator.Merge(other.calculator)
+		p.process()
+	}
+}
+
+func (p *PipelinesCalculator) Finish() {
+	p.calculator.Finish()
+	p.process()
+}
+
+func (p *PipelinesCalculator) process() {
+	p.buckets = nil
+	if calc, ok := p.calculator.(search.BucketCalculator); ok {
+		// copied, so that pipelines do not reorder the buckets of the calculator
+		p.buckets = append(p.buckets, calc.Buckets()...)
+	}
+	for _, pipeline := range p.pipelines {
+		p.buckets = pipeline.Process(p.buckets)
+	}
+}
+
+// Buckets returns the buckets kept by the pipelines
+func (p *PipelinesCalculator) Buckets() []*search.Bucket {
+	return p.buckets
+}
+
+// Calculator returns the calculator of the bucket aggregation
+func (p *PipelinesCalculator) Calculator() search.Calculator {
+	return p.calculator
+}
+
+// BucketValue resolves a path to a value of the bucket, NaN if there is
+// none.  The path "_count" is the document count, "name" the value of a
+// metric and "name.metric" one of the metrics of a multi metric.
+func BucketValue(bucket *search.Bucket, path string) float64 {
+	if path == "_count" {
+		return float64(bucket.Count())
+	}
+	switch calc := bucket.Aggregation(path).(type) {
+	case search.MetricCalculator:
+		return calc.Value()
+	case search.DurationCalculator:
+		return float64(calc.Duration())
+	}
+	if dot := strings.LastIndexByte(path, '.'); dot > 0 {
+		if calc, ok := bucket.Aggregation(path[:dot]).(search.MultiMetricCalculator); ok {
+			return calc.MetricValue(path[dot+1:])
+		}
+	}
+	return math.NaN()
+}
+
+func bucketVars(bucket *search.Bucket, paths map[string]string) map[string]float64 {
+	rv := make(map[string]float64, len(paths))
+	for name, path := range paths {
+		rv[name] = BucketValue(bucket, path)
+	}
+	return rv
+}
+
+// PipelineValueCalculator holds a value computed by a pipeline
+type PipelineValueCalculator struct {
+	val float64
+}
+
+func (p *PipelineValueCalculator) Consume(*search.DocumentMatch) {}
+
+func (p *PipelineValueCalculator) Merge(search.Calculator) {}
+
+func (p *PipelineValueCalculator) Finish() {}
+
+func (p *PipelineValueCalculator) Value() float64 {
+	return p.val
+}
+
+func setPipelineValue(bucket *search.Bucket, name string, val float64) {
+	bucket.SetAggregation(name, &PipelineValueCalculator{val: val})
+}
+
+type DerivativePipeline struct {
+	name, path string
+}
+
+// Derivative sets the difference between the value of each bucket and
+// the one before it, NaN for the first bucket and around gaps
+func Derivative(name, path string) *DerivativePipeline {
+	return &DerivativePipeline{
+		name: name,
+		path: path,
+	}
+}
+
+func (d *DerivativePipeline) Process(buckets []*search.Bucket) []*search.Bucket {
+	prev := math.NaN()
+	for _, bucket := range buckets {
+		val := BucketValue(bucket, d.path)
+		setPipelineValue(bucket, d.name, val-prev)
+		prev = val
+	}
+	return buckets
+}
+
+type CumulativeSumPipeline struct {
+	name, path string
+}
+
+// CumulativeSum sets the sum of the values of the bucket and those
+// before it, skipping gaps
+func CumulativeSum(name, path string) *CumulativeSumPipeline {
+	return &CumulativeSumPipeline{
+		name: name,
+		path: path,
+	}
+}
+
+func (c *CumulativeSumPipeline) Process(buckets []*search.Bucket) []*search.Bucket {
+	var sum float64
+	for _, bucket := range buckets {
+		val := BucketValue(bucket, c.path)
+		if !math.IsNaN(val) {
+			sum += val
+		}
+		setPipelineValue(bucket, c.name, sum)
+	}
+	return buckets
+}
+
+// MovingFunc computes a value from a window of values, oldest first
+type MovingFunc func(values []float64) float64
+
+// MovingAvg is the unweighted average, NaN for an empty window
+func MovingAvg() MovingFunc {
+	return func(values []float64) float64 {
+		if len(values) == 0 {
+			return math.NaN()
+		}
+		var sum float64
+		for _, val := range values {
+			sum += val
+		}
+		return sum / float64(len(values))
+	}
+}
+
+// MovingEWMA is the exponentially weighted average, alpha between 0
+// and 1 weighs the newer values more as it increases
+func MovingEWMA(alpha float64) MovingFunc {
+	return func(values []float64) float64 {
+		if len(values) == 0 {
+			return math.NaN()
+		}
+		avg := values[0]
+		for _, val := range values[1:] {
+			avg = alpha*val + (1-alpha)*avg
+		}
+		return avg
+	}
+}
+
+type MovingFunctionPipeline struct {
+	name, path string
+	window     int
+	shift      int
+	fn         MovingFunc
+}
+
+// MovingFunction sets the result of the function over the values of
+// the window of buckets before each bucket, skipping gaps
+func MovingFunction(name, path string, window int, fn MovingFunc) *MovingFunctionPipeline {
+	return &MovingFunctionPipeline{
+		name:   name,
+		path:   path,
+		window: window,
+		fn:     fn,
+	}
+}
+
+// Shift moves the window forward, a shift of 1 includes the bucket itself
+func (m *MovingFunctionPipeline) Shift(shift int) *MovingFunctionPipeline {
+	m.shift = shift
+	return m
+}
+
+func (m *MovingFunctionPipeline) Process(buckets []*search.Bucket) []*search.Bucket {
+	values := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		values[i] = BucketValue(bucket, m.path)
+	}
+	var window []float64
+	for i, bucket := range buckets {
+		end := i + m.shift
+		start := end - m.window
+		if start < 0 {
+			start = 0
+		}
+		if end > len(values) {
+			end = len(values)
+		}
+		window = window[:0]
+		for j := start; j < end; j++ {
+			if !math.IsNaN(values[j]) {
+				window = append(window, values[j])
+			}
+		}
+		setPipelineValue(bucket, m.name, m.fn(window))
+	}
+	return buckets
+}
+
+type BucketScriptPipeline struct {
+	name   string
+	paths  map[string]string
+	script func(vars map[string]float64) float64
+}
+
+// BucketScript sets the result of the script over the values of the
+// paths of each bucket, keyed by variable name
+func BucketScript(name string, paths map[string]string, script func(vars map[string]float64) float64) *BucketScriptPipeline {
+	return &BucketScriptPipeline{
+		name:   name,
+		paths:  paths,
+		script: script,
+	}
+}
+
+func (b *BucketScriptPipeline) Process(buckets []*search.Bucket) []*search.Bucket {
+	for _, bucket := range buckets {
+		setPipelineValue(bucket, b.name, b.script(bucketVars(bucket, b.paths)))
+	}
+	return buckets
+}
+
+type BucketSelectorPipeline struct {
+	paths    map[string]string
+	selector func(vars map[string]float64) bool
+}
+
+// BucketSelector keeps the buckets for which the selector returns true,
+// given the values of the paths of the bucket keyed by variable name
+func BucketSelector(paths map[string]string, selector func(vars map[string]float64) bool) *BucketSelectorPipeline {
+	return &BucketSelectorPipeline{
+		paths:    paths,
+		selector: selector,
+	}
+}
+
+func (b *BucketSelectorPipeline) Process(buckets []*search.Bucket) []*search.Bucket {
+	rv := buckets[:0]
+	for _, bucket := range buckets {
+		if b.selector(bucketVars(bucket, b.paths)) {
+			rv = append(rv, bucket)
+		}
+	}
+	return rv
+}
+
+type bucketSortPath struct {
+	path string
+	desc bool
+}
+
+type BucketSortPipeline struct {
+	sorts []bucketSortPath
+	from  int
+	size  int
+}
+
+// BucketSort orders the buckets by the values of the paths added with
+// SortBy, keeping the order of equal buckets, then keeps size of them
+// starting at from.  Without any path, the buckets are only truncated.
+func BucketSort() *BucketSortPipeline {
+	return &BucketSortPipeline{
+		size: -1,
+	}
+}
+
+// SortBy adds a path to order by, buckets without a value for it last
+func (b *BucketSortPipeline) SortBy(path string, desc bool) *BucketSortPipeline {
+	b.sorts = append(b.sorts, bucketSortPath{
+		path: path,
+		desc: desc,
+	})
+	return b
+}
+
+func (b *BucketSortPipeline) From(from int) *BucketSortPipeline {
+	b.from = from
+	return b
+}
+
+// Size limits the number of buckets kept, all of them by default
+func (b *BucketSortPipeline) Size(size int) *BucketSortPipeline {
+	b.size = size
+	return b
+}
+
+func (b *BucketSortPipeline) Process(buckets []*search.Bucket) []*search.Bucket {
+	if len(b.sorts) > 0 {
+		values := make(map[*search.Bucket][]float64, len(buckets))
+		for _, bucket := range buckets {
+			for _, s := range b.sorts {
+				values[bucket] = append(values[bucket], BucketValue(bucket, s.path))
+			}
+		}
+		sort.SliceStable(buckets, func(i, j int) bool {
+			iValues, jValues := values[buckets[i]], values[buckets[j]]
+			for x, s := range b.sorts {
+				iVal, jVal := iValues[x], jValues[x]
+				switch {
+				case iVal == jVal || math.IsNaN(iVal) && math.IsNaN(jVal):
+					continue
+				case math.IsNaN(iVal):
+					return false
+				case math.IsNaN(jVal):
+					return true
+				case s.desc:
+					return iVal > jVal
+				default:
+					return iVal < jVal
+				}
+			}
+			return false
+		})
+	}
+	if b.from >= len(buckets) {
+		return buckets[:0]
+	}
+	buckets = buckets[b.from:]
+	if b.size >= 0 && len(buckets) > b.size {
+		buckets = buckets[:b.size]
+	}
+	return buckets
+}
+
+// SiblingFunc computes a metric from the values of the buckets of a
+// sibling bucket aggregation, in the order of the buckets
+type SiblingFunc func(values []float64) float64
+
+// SiblingPipelineAggregation is a metric computed from the buckets of
+// another bucket aggregation of the same bucket, its sibling, once the
+// buckets are finished.  Unlike a PipelineAggregation it is added to the
+// bucket alongside the bucket aggregation, rather than wrapping it.
+type SiblingPipelineAggregation struct {
+	buckets, path string
+	fn            SiblingFunc
+}
+
+// SiblingPipeline computes the function over the values of the path of
+// the buckets of the named bucket aggregation, skipping gaps
+func SiblingPipeline(buckets, path string, fn SiblingFunc) *SiblingPipelineAggregation {
+	return &SiblingPipelineAggregation{
+		buckets: buckets,
+		path:    path,
+		fn:      fn,
+	}
+}
+
+// MaxBucket is the greatest value of the path of the buckets of the
+// named bucket aggregation, NaN if there are none
+func MaxBucket(buckets, path string) *SiblingPipelineAggregation {
+	return SiblingPipeline(buckets, path, func(values []float64) float64 {
+		rv := math.NaN()
+		for i, val := range values {
+			if i == 0 || val > rv {
+				rv = val
+			}
+		}
+		return rv
+	})
+}
+
+// MinBucket is the least value of the path of the buckets of the
+// named bucket aggregation, NaN if there are none
+func MinBucket(buckets, path string) *SiblingPipelineAggregation {
+	return SiblingPipeline(buckets, path, func(values []float64) float64 {
+		rv := math.NaN()
+		for i, val := range values {
+			if i == 0 || val < rv {
+				rv = val
+			}
+		}
+		return rv
+	})
+}
+
+// AvgBucket is the average value of the path of the buckets of the
+// named bucket aggregation, NaN if there are none
+func AvgBucket(buckets, path string) *SiblingPipelineAggregation {
+	return SiblingPipeline(buckets, path, SiblingFunc(MovingAvg()))
+}
+
+// SumBucket is the sum of the values of the path of the buckets of
+// the named bucket aggregation
+func SumBucket(buckets, path string) *SiblingPipelineAggregation {
+	return SiblingPipeline(buckets, path, func(values []float64) float64 {
+		var rv float64
+		for _, val := range values {
+			rv += val
+		}
+		return rv
+	})
+}
+
+func (a *SiblingPipelineAggregation) Fields() []string {
+	return nil
+}
+
+func (a *SiblingPipelineAggregation) Calculator() search.Calculator {
+	return &SiblingPipelineCalculator{
+		agg: a,
+	}
+}
+
+// SiblingPipelineCalculator computes its value from the buckets of its
+// sibling when asked for it, so that it reflects any merged buckets
+type SiblingPipelineCalculator struct {
+	agg    *SiblingPipelineAggregation
+	bucket *search.Bucket
+}
+
+func (s *SiblingPipelineCalculator) SetBucket(bucket *search.Bucket) {
+	s.bucket = bucket
+}
+
+func (s *SiblingPipelineCalculator) Consume(*search.DocumentMatch) {}
+
+func (s *SiblingPipelineCalculator) Merge(search.Calculator) {}
+
+func (s *SiblingPipelineCalculator) Finish() {}
+
+func (s *SiblingPipelineCalculator) Value() float64 {
+	var values []float64
+	if s.bucket != nil {
+		for _, bucket := range s.bucket.Buckets(s.agg.buckets) {
+			val := BucketValue(bucket, s.agg.path)
+			if !math.IsNaN(val) {
+				values = append(values, val)
+			}
+		}
+	}
+	return s.agg.fn(values)
+}
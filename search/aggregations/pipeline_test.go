@@ -0,0 +1,196 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+func TestPipelineMetrics(t *testing.T) {
+	// buckets 0, 20, 40, 60 and 80 with counts 4, 3, 1, 1, 1
+	// and age sums 32, 96, 48, 63, 95
+	nan := math.NaN()
+	expect := map[string][]float64{
+		"count_derivative": {nan, -1, -2, 0, 0},
+		"cumulative_sum":   {32, 128, 176, 239, 334},
+		"moving_avg":       {nan, 32, 64, 72, 55.5},
+		"moving_avg_self":  {32, 64, 72, 55.5, 79},
+		"ewma":             {nan, 32, 64, 56, 67.5},
+		"avg":              {8, 32, 48, 63, 95},
+	}
+
+	for _, shards := range []int{1, 2, 3} {
+		agg := WithPipelines(
+			Histogram(search.Field("age"), 20, 0).AddAggregation("sum", Sum(search.Field("age"))),
+			Derivative("count_derivative", "_count"),
+			CumulativeSum("cumulative_sum", "sum"),
+			MovingFunction("moving_avg", "sum", 2, MovingAvg()),
+			MovingFunction("moving_avg_self", "sum", 2, MovingAvg()).Shift(1),
+			MovingFunction("ewma", "sum", 3, MovingEWMA(0.5)),
+			BucketScript("avg", map[string]string{"sum": "sum", "count": "_count"},
+				func(vars map[string]float64) float64 {
+					return vars["sum"] / vars["count"]
+				}),
+		)
+		calc := consumeTestDocs(t, agg, shards).(*PipelinesCalculator)
+		buckets := calc.Buckets()
+		if len(buckets) != 5 {
+			t.Fatalf("%d shards: expected 5 buckets, got %d", shards, len(buckets))
+		}
+		for name, values := range expect {
+			for i, bucket := range buckets {
+				actual := bucket.Metric(name)
+				if math.IsNaN(values[i]) != math.IsNaN(actual) ||
+					!math.IsNaN(actual) && math.Abs(actual-values[i]) > 1e-9 {
+					t.Errorf("%d shards: expected %s of bucket %s to be %f, got %f",
+						shards, name, bucket.Name(), values[i], actual)
+				}
+			}
+		}
+	}
+}
+
+func TestPipelineBuckets(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline []PipelineAggregation
+		expect   []string
+	}{
+		{
+			name: "selector",
+			pipeline: []PipelineAggregation{
+				BucketSelector(map[string]string{"count": "_count"}, func(vars map[string]float64) bool {
+					return vars["count"] >= 2
+				}),
+			},
+			expect: []string{"0", "20"},
+		},
+		{
+			name: "sort",
+			pipeline: []PipelineAggregation{
+				BucketSort().SortBy("sum", true).Size(2),
+			},
+			expect: []string{"20", "80"},
+		},
+		{
+			name: "sort from",
+			pipeline: []PipelineAggregation{
+				BucketSort().SortBy("_count", true).SortBy("sum", false).From(1).Size(2),
+			},
+			expect: []string{"20", "40"},
+		},
+		{
+			name: "truncate",
+			pipeline: []PipelineAggregation{
+				BucketSort().From(3),
+			},
+			expect: []string{"60", "80"},
+		},
+		{
+			name: "selector on pipeline value",
+			pipeline: []PipelineAggregation{
+				Derivative("derivative", "sum"),
+				BucketSelector(map[string]string{"derivative": "derivative"}, func(vars map[string]float64) bool {
+					return vars["derivative"] > 0
+				}),
+			},
+			expect: []string{"20", "60", "80"},
+		},
+	}
+
+	for _, test := range tests {
+		for _, shards := range []int{1, 2} {
+			agg := WithPipelines(
+				Histogram(search.Field("age"), 20, 0).AddAggregation("sum", Sum(search.Field("age"))),
+				test.pipeline...)
+			calc := consumeTestDocs(t, agg, shards).(*PipelinesCalculator)
+			if !reflect.DeepEqual(bucketNames(calc.Buckets()), test.expect) {
+				t.Errorf("%s with %d shards: expected buckets %v, got %v", test.name, shards,
+					test.expect, bucketNames(calc.Buckets()))
+			}
+			// the buckets of the histogram itself are not affected
+			if len(calc.Calculator().(*HistogramCalculator).Buckets()) != 5 {
+				t.Errorf("%s with %d shards: expected the histogram to keep its buckets", test.name, shards)
+			}
+		}
+	}
+}
+
+func TestSiblingPipelines(t *testing.T) {
+	// buckets 0, 20, 40, 60 and 80 with counts 4, 3, 1, 1, 1
+	// and age sums 32, 96, 48, 63, 95
+	expect := map[string]float64{
+		"max_sum":   96,
+		"min_sum":   32,
+		"avg_sum":   66.8,
+		"sum_count": 10,
+		"max_avg":   95,
+	}
+
+	for _, shards := range []int{1, 2, 3} {
+		aggs := search.Aggregations{
+			"ages": WithPipelines(
+				Histogram(search.Field("age"), 20, 0).AddAggregation("sum", Sum(search.Field("age"))),
+				BucketScript("avg", map[string]string{"sum": "sum", "count": "_count"},
+					func(vars map[string]float64) float64 {
+						return vars["sum"] / vars["count"]
+					}),
+			),
+			"max_sum":   MaxBucket("ages", "sum"),
+			"min_sum":   MinBucket("ages", "sum"),
+			"avg_sum":   AvgBucket("ages", "sum"),
+			"sum_count": SumBucket("ages", "_count"),
+			"max_avg":   MaxBucket("ages", "avg"),
+			"missing":   MaxBucket("missing", "sum"),
+		}
+		bucket := consumeTestDocsBucket(t, aggs, shards)
+		for name, val := range expect {
+			if math.Abs(bucket.Metric(name)-val) > 1e-9 {
+				t.Errorf("%d shards: expected %s to be %f, got %f", shards, name, val, bucket.Metric(name))
+			}
+		}
+		if !math.IsNaN(bucket.Metric("missing")) {
+			t.Errorf("%d shards: expected missing buckets to be NaN, got %f", shards, bucket.Metric("missing"))
+		}
+	}
+}
+
+func TestBucketValue(t *testing.T) {
+	bucket := search.NewBucket("", map[string]search.Aggregation{
+		"count": CountMatches(),
+		"stats": ExtendedStats(search.Field("age")),
+	})
+	for _, doc := range buildTestDocs() {
+		err := doc.LoadDocumentValues(search.NewSearchContext(0, 0), []string{"age"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bucket.Consume(doc)
+	}
+	bucket.Finish()
+	if BucketValue(bucket, "_count") != 10 || BucketValue(bucket, "count") != 10 {
+		t.Errorf("expected count 10, got %f", BucketValue(bucket, "_count"))
+	}
+	if BucketValue(bucket, "stats.max") != 95 {
+		t.Errorf("expected max 95, got %f", BucketValue(bucket, "stats.max"))
+	}
+	if !math.IsNaN(BucketValue(bucket, "missing")) || !math.IsNaN(BucketValue(bucket, "count.max")) {
+		t.Errorf("expected missing values to be NaN")
+	}
+}
@@ -17,7 +17,7 @@ package aggregations
 import (
 	"fmt"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type RangeAggregation struct {
@@ -0,0 +1,393 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"sort"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// SignificanceHeuristic scores how much more frequent a term is in the
+// subset of matching documents than in the superset of all documents
+type SignificanceHeuristic interface {
+	Score(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64
+}
+
+type SignificanceHeuristicFunc func(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64
+
+func (f SignificanceHeuristicFunc) Score(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64 {
+	return f(subsetFreq, subsetSize, supersetFreq, supersetSize)
+}
+
+// JLH multiplies the absolute and relative changes in the probability
+// of a document having the term, terms less frequent in the subset score 0
+func JLH() SignificanceHeuristic {
+	return SignificanceHeuristicFunc(func(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64 {
+		if subsetSize == 0 || supersetSize == 0 || supersetFreq == 0 {
+			return 0
+		}
+		subsetProbability := float64(subsetFreq) / float64(subsetSize)
+		supersetProbability := float64(supersetFreq) / float64(supersetSize)
+		absoluteChange := subsetProbability - supersetProbability
+		if absoluteChange <= 0 {
+			return 0
+		}
+		return absoluteChange * (subsetProbability / supersetProbability)
+	})
+}
+
+// Percentage is the fraction of the documents having the term
+// which are in the subset
+func Percentage() SignificanceHeuristic {
+	return SignificanceHeuristicFunc(func(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64 {
+		if supersetFreq == 0 {
+			return 0
+		}
+		return float64(subsetFreq) / float64(supersetFreq)
+	})
+}
+
+// MutualInformation measures how much knowing whether a document has the
+// term tells about whether it is in the subset.  Unless includeNegatives
+// is set, terms less frequent in the subset score 0.
+func MutualInformation(includeNegatives bool) SignificanceHeuristic {
+	return SignificanceHeuristicFunc(func(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64 {
+		c, ok := newContingency(subsetFreq, subsetSize, supersetFreq, supersetSize, includeNegatives)
+		if !ok {
+			return 0
+		}
+		miTerm := func(nxy, nx, ny float64) float64 {
+			if nxy == 0 || nx == 0 || ny == 0 {
+				return 0
+			}
+			return nxy / c.n * math.Log2(c.n*nxy/(nx*ny))
+		}
+		return miTerm(c.n11, c.n1x, c.nx1) +
+			miTerm(c.n01, c.n0x, c.nx1) +
+			miTerm(c.n10, c.n1x, c.nx0) +
+			miTerm(c.n00, c.n0x, c.nx0)
+	})
+}
+
+// ChiSquare measures the dependence between a document having the term
+// and being in the subset.  Unless includeNegatives is set, terms less
+// frequent in the subset score 0.
+func ChiSquare(includeNegatives bool) SignificanceHeuristic {
+	return SignificanceHeuristicFunc(func(subsetFreq, subsetSize, supersetFreq, supersetSize uint64) float64 {
+		c, ok := newContingency(subsetFreq, subsetSize, supersetFreq, supersetSize, includeNegatives)
+		if !ok {
+			return 0
+		}
+		denominator := c.nx1 * c.nx0 * c.n1x * c.n0x
+		if denominator == 0 {
+			return 0
+		}
+		diff := c.n11*c.n00 - c.n10*c.n01
+		return c.n * diff * diff / denominator
+	})
+}
+
+// contingency counts the documents by whether they have the term (the
+// first digit) and whether they are in the subset (the second digit),
+// x counts either, the subset is part of the superset
+type contingency struct {
+	n00, n01, n10, n11 float64
+	n0x, n1x, nx0, nx1 float64
+	n                  float64
+}
+
+func newContingency(subsetFreq, subsetSize, supersetFreq, supersetSize uint64,
+	includeNegatives bool) (*contingency, bool) {
+	if subsetSize == 0 || supersetSize <= subsetSize || supersetFreq < subsetFreq {
+		return nil, false
+	}
+	rv := &contingency{
+		n11: float64(subsetFreq),
+		n01: float64(subsetSize - subsetFreq),
+		n10: float64(supersetFreq - subsetFreq),
+		n1x: float64(supersetFreq),
+		nx1: float64(subsetSize),
+		nx0: float64(supersetSize - subsetSize),
+		n:   float64(supersetSize),
+	}
+	rv.n00 = rv.nx0 - rv.n10
+	rv.n0x = rv.n - rv.n1x
+	if rv.n00 < 0 {
+		return nil, false
+	}
+	if !includeNegatives && rv.n11/rv.nx1 < rv.n10/rv.nx0 {
+		return nil, false
+	}
+	return rv, true
+}
+
+// SignificantTermsAggregation buckets the terms of a field which are
+// unusually frequent in the matching documents, compared with the
+// document frequencies in the term dictionaries of the readers searched.
+// Those are the readers passed to WithReaders, which a search across
+// several readers does for a top level aggregation, or otherwise the
+// readers of the documents consumed.
+type SignificantTermsAggregation struct {
+	field       string
+	src         search.TextValuesSource
+	size        int
+	minDocCount uint64
+	heuristic   SignificanceHeuristic
+	readers     []search.Reader
+
+	aggregations map[string]search.Aggregation
+}
+
+// SignificantTerms returns the size most significant terms of the field,
+// scored by JLH and present in at least 3 matching documents by default
+func SignificantTerms(field string, size int) *SignificantTermsAggregation {
+	return &SignificantTermsAggregation{
+		field:       field,
+		src:         search.Field(field),
+		size:        size,
+		minDocCount: 3,
+		heuristic:   JLH(),
+		aggregations: map[string]search.Aggregation{
+			"count": CountMatches(),
+		},
+	}
+}
+
+func (a *SignificantTermsAggregation) Heuristic(heuristic SignificanceHeuristic) *SignificantTermsAggregation {
+	a.heuristic = heuristic
+	return a
+}
+
+// MinDocCount omits the terms in fewer matching documents
+func (a *SignificantTermsAggregation) MinDocCount(minDocCount uint64) *SignificantTermsAggregation {
+	a.minDocCount = minDocCount
+	return a
+}
+
+// WithReaders returns a copy of the aggregation taking the background
+// frequencies from the readers, rather than from the readers of the
+// documents consumed
+func (a *SignificantTermsAggregation) WithReaders(readers []search.Reader) search.Aggregation {
+	rv := *a
+	rv.readers = readers
+	return &rv
+}
+
+func (a *SignificantTermsAggregation) AddAggregation(name string, agg search.Aggregation) *SignificantTermsAggregation {
+	a.aggregations[name] = agg
+	return a
+}
+
+func (a *SignificantTermsAggregation) Fields() []string {
+	rv := a.src.Fields()
+	for _, agg := range a.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (a *SignificantTermsAggregation) Calculator() search.Calculator {
+	return &SignificantTermsCalculator{
+		agg:        a,
+		bucketsMap: make(map[string]*search.Bucket),
+		background: make(map[string]uint64),
+		scores:     make(map[string]float64),
+	}
+}
+
+// SignificantTermsCalculator remembers the readers of the documents it
+// consumes, to look up the background frequencies of the terms in them,
+// unless the aggregation has the readers searched
+type SignificantTermsCalculator struct {
+	agg *SignificantTermsAggregation
+
+	readers      []search.Reader
+	subsetSize   uint64
+	supersetSize uint64
+
+	bucketsMap  map[string]*search.Bucket
+	background  map[string]uint64
+	scores      map[string]float64
+	bucketsList []*search.Bucket
+	terms       []string
+}
+
+func (s *SignificantTermsCalculator) Consume(d *search.DocumentMatch) {
+	s.subsetSize++
+	if s.agg.readers == nil {
+		if reader, ok := d.Reader().(search.Reader); ok {
+			s.addReader(reader)
+		}
+	}
+	s.terms = s.terms[:0]
+	for _, term := range s.agg.src.Values(d) {
+		termStr := string(term)
+		if containsString(s.terms, termStr) {
+			continue
+		}
+		s.terms = append(s.terms, termStr)
+		bucket, ok := s.bucketsMap[termStr]
+		if !ok {
+			bucket = search.NewBucket(termStr, s.agg.aggregations)
+			s.bucketsMap[termStr] = bucket
+		}
+		bucket.Consume(d)
+	}
+}
+
+func (s *SignificantTermsCalculator) addReader(reader search.Reader) {
+	for _, existing := range s.readers {
+		if existing == reader {
+			return
+		}
+	}
+	s.readers = append(s.readers, reader)
+}
+
+func (s *SignificantTermsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*SignificantTermsCalculator); ok {
+		s.subsetSize += other.subsetSize
+		if s.agg.readers != nil {
+			s.mergeBuckets(other)
+			return
+		}
+		// the terms only one side has seen still need the background
+		// frequencies from the readers of the other side
+		for term := range s.bucketsMap {
+			if _, ok := other.bucketsMap[term]; !ok {
+				s.background[term] += backgroundFrequency(other.readers, s.agg.field, term)
+			}
+		}
+		for term, otherBucket := range other.bucketsMap {
+			if bucket, ok := s.bucketsMap[term]; ok {
+				bucket.Merge(otherBucket)
+				s.background[term] += other.background[term]
+			} else {
+				s.bucketsMap[term] = otherBucket
+				s.background[term] = other.background[term] +
+					backgroundFrequency(s.readers, s.agg.field, term)
+			}
+		}
+		for _, reader := range other.readers {
+			s.addReader(reader)
+		}
+		s.supersetSize += other.supersetSize
+		s.build()
+	}
+}
+
+// mergeBuckets merges the buckets of a calculator with the same readers
+// searched, so the background frequencies are the same on both sides
+func (s *SignificantTermsCalculator) mergeBuckets(other *SignificantTermsCalculator) {
+	for term, otherBucket := range other.bucketsMap {
+		if bucket, ok := s.bucketsMap[term]; ok {
+			bucket.Merge(otherBucket)
+		} else {
+			s.bucketsMap[term] = otherBucket
+			s.background[term] = other.background[term]
+		}
+	}
+	if other.supersetSize > s.supersetSize {
+		s.supersetSize = other.supersetSize
+	}
+	s.build()
+}
+
+func (s *SignificantTermsCalculator) Finish() {
+	readers := s.readers
+	if s.agg.readers != nil {
+		readers = s.agg.readers
+	}
+	s.supersetSize = 0
+	for _, reader := range readers {
+		if stats, err := reader.CollectionStats(s.agg.field); err == nil {
+			s.supersetSize += stats.TotalDocumentCount()
+		}
+	}
+	for term, bucket := range s.bucketsMap {
+		bucket.Finish()
+		s.background[term] = backgroundFrequency(readers, s.agg.field, term)
+	}
+	s.build()
+}
+
+// backgroundFrequency sums the number of documents with the term in the
+// readers, those it cannot be looked up in are not counted
+func backgroundFrequency(readers []search.Reader, field, term string) uint64 {
+	var rv uint64
+	for _, reader := range readers {
+		postings, err := reader.PostingsIterator([]byte(term), field, false, false, false)
+		if err != nil {
+			continue
+		}
+		rv += postings.Count()
+		_ = postings.Close()
+	}
+	return rv
+}
+
+// build scores the terms, keeping the most significant ones in the list,
+// the map keeps all of them so that they can still be merged
+func (s *SignificantTermsCalculator) build() {
+	s.bucketsList = s.bucketsList[:0]
+	for term, bucket := range s.bucketsMap {
+		if bucket.Count() < s.agg.minDocCount {
+			continue
+		}
+		score := s.agg.heuristic.Score(bucket.Count(), s.subsetSize, s.background[term], s.supersetSize)
+		s.scores[term] = score
+		if score > 0 && !math.IsInf(score, 0) && !math.IsNaN(score) {
+			s.bucketsList = append(s.bucketsList, bucket)
+		}
+	}
+	sort.Slice(s.bucketsList, func(i, j int) bool {
+		iScore := s.scores[s.bucketsList[i].Name()]
+		jScore := s.scores[s.bucketsList[j].Name()]
+		if iScore != jScore {
+			return iScore > jScore
+		}
+		return s.bucketsList[i].Name() < s.bucketsList[j].Name()
+	})
+	if s.agg.size >= 0 && len(s.bucketsList) > s.agg.size {
+		s.bucketsList = s.bucketsList[:s.agg.size]
+	}
+}
+
+func (s *SignificantTermsCalculator) Buckets() []*search.Bucket {
+	return s.bucketsList
+}
+
+// Score returns the significance of the term
+func (s *SignificantTermsCalculator) Score(term string) float64 {
+	return s.scores[term]
+}
+
+// BackgroundCount returns the number of documents having the term
+// in the readers searched
+func (s *SignificantTermsCalculator) BackgroundCount(term string) uint64 {
+	return s.background[term]
+}
+
+// SubsetSize returns the number of matching documents
+func (s *SignificantTermsCalculator) SubsetSize() uint64 {
+	return s.subsetSize
+}
+
+// SupersetSize returns the number of documents in the readers searched
+func (s *SignificantTermsCalculator) SupersetSize() uint64 {
+	return s.supersetSize
+}
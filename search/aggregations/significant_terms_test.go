@@ -0,0 +1,49 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSignificanceHeuristics(t *testing.T) {
+	tests := []struct {
+		name                                               string
+		heuristic                                          SignificanceHeuristic
+		subsetFreq, subsetSize, supersetFreq, supersetSize uint64
+		expect                                             float64
+	}{
+		{"jlh", JLH(), 4, 10, 5, 20, 0.24},
+		{"jlh less frequent", JLH(), 1, 10, 5, 20, 0},
+		{"jlh empty background", JLH(), 0, 0, 0, 0, 0},
+		{"percentage", Percentage(), 4, 10, 5, 20, 0.8},
+		// 20 * (4*9 - 1*6)^2 / (10*10*5*15)
+		{"chi square", ChiSquare(false), 4, 10, 5, 20, 2.4},
+		{"chi square less frequent", ChiSquare(false), 1, 10, 5, 20, 0},
+		{"chi square negatives", ChiSquare(true), 1, 10, 5, 20, 2.4},
+		{"mutual information", MutualInformation(false), 4, 10, 5, 20,
+			0.2*math.Log2(1.6) + 0.3*math.Log2(0.8) + 0.05*math.Log2(0.4) + 0.45*math.Log2(1.2)},
+		{"mutual information less frequent", MutualInformation(false), 1, 10, 5, 20, 0},
+		{"mutual information independent", MutualInformation(true), 5, 10, 10, 20, 0},
+	}
+
+	for _, test := range tests {
+		actual := test.heuristic.Score(test.subsetFreq, test.subsetSize, test.supersetFreq, test.supersetSize)
+		if math.Abs(actual-test.expect) > 1e-9 {
+			t.Errorf("%s: expected %f, got %f", test.name, test.expect, actual)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// the metrics of ExtendedStatsCalculator
+const (
+	StatsCount                = "count"
+	StatsMin                  = "min"
+	StatsMax                  = "max"
+	StatsSum                  = "sum"
+	StatsAvg                  = "avg"
+	StatsSumOfSquares         = "sum_of_squares"
+	StatsVariance             = "variance"
+	StatsVarianceSampling     = "variance_sampling"
+	StatsStdDeviation         = "std_deviation"
+	StatsStdDeviationSampling = "std_deviation_sampling"
+	StatsStdUpper             = "std_upper"
+	StatsStdLower             = "std_lower"
+)
+
+var extendedStatsMetrics = []string{
+	StatsCount, StatsMin, StatsMax, StatsSum, StatsAvg, StatsSumOfSquares,
+	StatsVariance, StatsVarianceSampling, StatsStdDeviation,
+	StatsStdDeviationSampling, StatsStdUpper, StatsStdLower,
+}
+
+type ExtendedStatsMetric struct {
+	src   search.NumericValuesSource
+	sigma float64
+}
+
+// ExtendedStats computes the count, min, max, sum, average, sum of
+// squares, variance and standard deviation of the values, with bounds
+// 2 standard deviations from the average by default
+func ExtendedStats(src search.NumericValuesSource) *ExtendedStatsMetric {
+	return &ExtendedStatsMetric{
+		src:   src,
+		sigma: 2,
+	}
+}
+
+// Sigma sets the number of standard deviations of the bounds
+func (e *ExtendedStatsMetric) Sigma(sigma float64) *ExtendedStatsMetric {
+	e.sigma = sigma
+	return e
+}
+
+func (e *ExtendedStatsMetric) Fields() []string {
+	return e.src.Fields()
+}
+
+func (e *ExtendedStatsMetric) Calculator() search.Calculator {
+	return &ExtendedStatsCalculator{
+		src:   e.src,
+		sigma: e.sigma,
+		min:   math.Inf(1),
+		max:   math.Inf(-1),
+	}
+}
+
+// ExtendedStatsCalculator keeps the running mean and sum of squared
+// differences from it, which merge exactly and avoid the cancellation
+// of computing the variance from the sum of squares
+type ExtendedStatsCalculator struct {
+	src   search.NumericValuesSource
+	sigma float64
+
+	count        uint64
+	min, max     float64
+	sum          float64
+	sumOfSquares float64
+	mean         float64
+	m2           float64
+}
+
+func (e *ExtendedStatsCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range e.src.Numbers(d) {
+		e.count++
+		e.min = math.Min(e.min, val)
+		e.max = math.Max(e.max, val)
+		e.sum += val
+		e.sumOfSquares += val * val
+		delta := val - e.mean
+		e.mean += delta / float64(e.count)
+		e.m2 += delta * (val - e.mean)
+	}
+}
+
+func (e *ExtendedStatsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ExtendedStatsCalculator); ok {
+		if other.count == 0 {
+			return
+		}
+		count := e.count + other.count
+		delta := other.mean - e.mean
+		e.mean += delta * float64(other.count) / float64(count)
+		e.m2 += other.m2 + delta*delta*float64(e.count)*float64(other.count)/float64(count)
+		e.count = count
+		e.min = math.Min(e.min, other.min)
+		e.max = math.Max(e.max, other.max)
+		e.sum += other.sum
+		e.sumOfSquares += other.sumOfSquares
+	}
+}
+
+func (e *ExtendedStatsCalculator) Finish() {}
+
+func (e *ExtendedStatsCalculator) Count() uint64 {
+	return e.count
+}
+
+func (e *ExtendedStatsCalculator) Min() float64 {
+	return e.min
+}
+
+func (e *ExtendedStatsCalculator) Max() float64 {
+	return e.max
+}
+
+func (e *ExtendedStatsCalculator) Sum() float64 {
+	return e.sum
+}
+
+func (e *ExtendedStatsCalculator) SumOfSquares() float64 {
+	return e.sumOfSquares
+}
+
+// Avg is NaN without values
+func (e *ExtendedStatsCalculator) Avg() float64 {
+	if e.count == 0 {
+		return math.NaN()
+	}
+	return e.mean
+}
+
+// Variance is the population variance, NaN without values
+func (e *ExtendedStatsCalculator) Variance() float64 {
+	if e.count == 0 {
+		return math.NaN()
+	}
+	return e.m2 / float64(e.count)
+}
+
+// VarianceSampling is the sample variance, NaN with fewer than 2 values
+func (e *ExtendedStatsCalculator) VarianceSampling() float64 {
+	if e.count < 2 {
+		return math.NaN()
+	}
+	return e.m2 / float64(e.count-1)
+}
+
+func (e *ExtendedStatsCalculator) StdDeviation() float64 {
+	return math.Sqrt(e.Variance())
+}
+
+func (e *ExtendedStatsCalculator) StdDeviationSampling() float64 {
+	return math.Sqrt(e.VarianceSampling())
+}
+
+// StdUpper is the average plus sigma standard deviations
+func (e *ExtendedStatsCalculator) StdUpper() float64 {
+	return e.Avg() + e.sigma*e.StdDeviation()
+}
+
+// StdLower is the average minus sigma standard deviations
+func (e *ExtendedStatsCalculator) StdLower() float64 {
+	return e.Avg() - e.sigma*e.StdDeviation()
+}
+
+func (e *ExtendedStatsCalculator) Metrics() []string {
+	return extendedStatsMetrics
+}
+
+// MetricValue returns the named metric, NaN for unknown names
+func (e *ExtendedStatsCalculator) MetricValue(name string) float64 {
+	switch name {
+	case StatsCount:
+		return float64(e.Count())
+	case StatsMin:
+		return e.Min()
+	case StatsMax:
+		return e.Max()
+	case StatsSum:
+		return e.Sum()
+	case StatsAvg:
+		return e.Avg()
+	case StatsSumOfSquares:
+		return e.SumOfSquares()
+	case StatsVariance:
+		return e.Variance()
+	case StatsVarianceSampling:
+		return e.VarianceSampling()
+	case StatsStdDeviation:
+		return e.StdDeviation()
+	case StatsStdDeviationSampling:
+		return e.StdDeviationSampling()
+	case StatsStdUpper:
+		return e.StdUpper()
+	case StatsStdLower:
+		return e.StdLower()
+	}
+	return math.NaN()
+}
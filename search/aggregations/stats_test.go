@@ -0,0 +1,67 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"math"
+	"testing"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+func TestExtendedStats(t *testing.T) {
+	ages := []float64{1, 25, 16, 32, 48, 63, 4, 95, 39, 11}
+	var sum, sumOfSquares float64
+	for _, age := range ages {
+		sum += age
+		sumOfSquares += age * age
+	}
+	avg := sum / 10
+	variance := sumOfSquares/10 - avg*avg
+	expect := map[string]float64{
+		StatsCount:                10,
+		StatsMin:                  1,
+		StatsMax:                  95,
+		StatsSum:                  sum,
+		StatsAvg:                  avg,
+		StatsSumOfSquares:         sumOfSquares,
+		StatsVariance:             variance,
+		StatsVarianceSampling:     variance * 10 / 9,
+		StatsStdDeviation:         math.Sqrt(variance),
+		StatsStdDeviationSampling: math.Sqrt(variance * 10 / 9),
+		StatsStdUpper:             avg + 3*math.Sqrt(variance),
+		StatsStdLower:             avg - 3*math.Sqrt(variance),
+	}
+
+	for _, shards := range []int{1, 2, 3, 10} {
+		calc := consumeTestDocs(t, ExtendedStats(search.Field("age")).Sigma(3), shards).(search.MultiMetricCalculator)
+		if len(calc.Metrics()) != len(expect) {
+			t.Errorf("expected %d metrics, got %d", len(expect), len(calc.Metrics()))
+		}
+		for _, metric := range calc.Metrics() {
+			if math.Abs(calc.MetricValue(metric)-expect[metric]) > 1e-9 {
+				t.Errorf("%d shards: expected %s %f, got %f", shards, metric,
+					expect[metric], calc.MetricValue(metric))
+			}
+		}
+	}
+
+	empty := ExtendedStats(search.Field("age")).Calculator().(*ExtendedStatsCalculator)
+	empty.Merge(ExtendedStats(search.Field("age")).Calculator())
+	if empty.Count() != 0 || !math.IsNaN(empty.Avg()) || !math.IsNaN(empty.StdDeviation()) {
+		t.Errorf("expected no count and undefined average and deviation, got %d %f %f",
+			empty.Count(), empty.Avg(), empty.StdDeviation())
+	}
+}
@@ -17,7 +17,7 @@ package aggregations
 import (
 	"sort"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type TermsAggregation struct {
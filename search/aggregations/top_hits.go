@@ -0,0 +1,182 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+type TopHitsAggregation struct {
+	size         int
+	sortOrder    search.SortOrder
+	storedFields []string
+}
+
+// TopHits keeps the size best documents in the sort order, by
+// descending score when the sort order is empty
+func TopHits(size int, sortOrder search.SortOrder) *TopHitsAggregation {
+	if len(sortOrder) == 0 {
+		sortOrder = search.SortOrder{search.SortBy(search.DocumentScore()).Desc()}
+	}
+	return &TopHitsAggregation{
+		size:      size,
+		sortOrder: sortOrder,
+	}
+}
+
+// StoredFields loads the listed stored fields of the hits, or all of
+// them if none are listed, when Hits is called
+func (a *TopHitsAggregation) StoredFields(fields ...string) *TopHitsAggregation {
+	if fields == nil {
+		fields = []string{}
+	}
+	a.storedFields = fields
+	return a
+}
+
+func (a *TopHitsAggregation) Fields() []string {
+	return a.sortOrder.Fields()
+}
+
+func (a *TopHitsAggregation) Calculator() search.Calculator {
+	return &TopHitsCalculator{
+		agg:    a,
+		shards: 1,
+	}
+}
+
+// TopHit is a copy of a matching document, independent of the match
+// reused by the search, with its requested stored fields
+type TopHit struct {
+	Match    *search.DocumentMatch
+	Document *search.StoredDocument
+
+	// shard is the position of the calculator which consumed the match
+	// among those merged, hit numbers are only comparable within one
+	shard int
+}
+
+// TopHitsCalculator keeps the hits in a heap with the worst at the top,
+// so that it can be replaced when a better document is consumed.  Hits
+// with the same sort values are ordered by the calculator which consumed
+// them, in the order they were merged, and then by hit number, so that
+// ties are broken the same way however the readers were searched.
+type TopHitsCalculator struct {
+	agg    *TopHitsAggregation
+	hits   []*TopHit
+	shards int
+}
+
+func (t *TopHitsCalculator) Consume(d *search.DocumentMatch) {
+	if t.agg.size <= 0 {
+		return
+	}
+	hit := &TopHit{
+		shard: t.shards - 1,
+		Match: &search.DocumentMatch{
+			Number:      d.Number,
+			Score:       d.Score,
+			Explanation: d.Explanation,
+			HitNumber:   d.HitNumber,
+			SortValue:   make([][]byte, 0, len(t.agg.sortOrder)),
+		},
+	}
+	hit.Match.SetReader(d.Reader())
+	for _, sort := range t.agg.sortOrder {
+		hit.Match.SortValue = append(hit.Match.SortValue, append([]byte(nil), sort.Value(d)...))
+	}
+	t.add(hit)
+}
+
+func (t *TopHitsCalculator) add(hit *TopHit) {
+	if len(t.hits) < t.agg.size {
+		heap.Push(t, hit)
+	} else if t.compare(hit, t.hits[0]) < 0 {
+		t.hits[0] = hit
+		heap.Fix(t, 0)
+	}
+}
+
+func (t *TopHitsCalculator) compare(i, j *TopHit) int {
+	if c := t.agg.sortOrder.CompareValues(i.Match, j.Match); c != 0 {
+		return c
+	}
+	if i.shard != j.shard {
+		return i.shard - j.shard
+	}
+	return t.agg.sortOrder.Compare(i.Match, j.Match)
+}
+
+func (t *TopHitsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*TopHitsCalculator); ok {
+		for _, hit := range other.hits {
+			hit.shard += t.shards
+			t.add(hit)
+		}
+		t.shards += other.shards
+	}
+}
+
+func (t *TopHitsCalculator) Finish() {}
+
+// Hits returns the best documents, best first, loading their stored
+// fields if requested, which requires the readers searched to be open
+func (t *TopHitsCalculator) Hits() ([]*TopHit, error) {
+	rv := make([]*TopHit, len(t.hits))
+	copy(rv, t.hits)
+	sort.Slice(rv, func(i, j int) bool {
+		return t.compare(rv[i], rv[j]) < 0
+	})
+	if t.agg.storedFields != nil {
+		for _, hit := range rv {
+			if hit.Document != nil {
+				continue
+			}
+			doc, err := hit.Match.Document(t.agg.storedFields...)
+			if err != nil {
+				return nil, err
+			}
+			hit.Document = doc
+		}
+	}
+	return rv, nil
+}
+
+// heap interface implementation
+
+func (t *TopHitsCalculator) Len() int {
+	return len(t.hits)
+}
+
+func (t *TopHitsCalculator) Less(i, j int) bool {
+	return t.compare(t.hits[i], t.hits[j]) > 0
+}
+
+func (t *TopHitsCalculator) Swap(i, j int) {
+	t.hits[i], t.hits[j] = t.hits[j], t.hits[i]
+}
+
+func (t *TopHitsCalculator) Push(x interface{}) {
+	t.hits = append(t.hits, x.(*TopHit))
+}
+
+func (t *TopHitsCalculator) Pop() interface{} {
+	var rv *TopHit
+	rv, t.hits = t.hits[len(t.hits)-1], t.hits[:len(t.hits)-1]
+	return rv
+}
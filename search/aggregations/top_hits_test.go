@@ -0,0 +1,118 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregations
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// consumeTopHits consumes a document with the type for each number,
+// numbering the hits from 0 like each reader of a multi search does
+func consumeTopHits(t *testing.T, agg *TopHitsAggregation, numbers ...uint64) search.Calculator {
+	calc := agg.Calculator()
+	for i, number := range numbers {
+		d := newDocumentMatch(number, 1, map[string][]byte{"type": []byte("employee")})
+		d.HitNumber = i
+		err := d.LoadDocumentValues(search.NewSearchContext(0, 0), agg.Fields())
+		if err != nil {
+			t.Fatal(err)
+		}
+		calc.Consume(d)
+	}
+	calc.Finish()
+	return calc
+}
+
+func hitNumbers(t *testing.T, calc search.Calculator) []uint64 {
+	hits, err := calc.(*TopHitsCalculator).Hits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv := make([]uint64, len(hits))
+	for i, hit := range hits {
+		rv[i] = hit.Match.Number
+	}
+	return rv
+}
+
+func TestTopHits(t *testing.T) {
+	newAgg := func() *TopHitsAggregation {
+		return TopHits(3, search.SortOrder{search.SortBy(search.Field("type"))})
+	}
+
+	calc := consumeTopHits(t, newAgg(), 10, 11, 12, 13)
+	if !reflect.DeepEqual(hitNumbers(t, calc), []uint64{10, 11, 12}) {
+		t.Errorf("expected hits [10 11 12], got %v", hitNumbers(t, calc))
+	}
+
+	// ties are broken by the order the readers were merged in,
+	// even though their hit numbers are the same
+	for _, test := range []struct {
+		shards [][]uint64
+		expect []uint64
+	}{
+		{
+			shards: [][]uint64{{10, 11}, {20, 21}, {30}},
+			expect: []uint64{10, 11, 20},
+		},
+		{
+			shards: [][]uint64{{20, 21}, {10, 11}, {30}},
+			expect: []uint64{20, 21, 10},
+		},
+		{
+			shards: [][]uint64{{}, {30}, {20, 21}},
+			expect: []uint64{30, 20, 21},
+		},
+	} {
+		agg := newAgg()
+		merged := consumeTopHits(t, agg, test.shards[0]...)
+		for _, shard := range test.shards[1:] {
+			merged.Merge(consumeTopHits(t, agg, shard...))
+		}
+		if !reflect.DeepEqual(hitNumbers(t, merged), test.expect) {
+			t.Errorf("%v: expected hits %v, got %v", test.shards, test.expect, hitNumbers(t, merged))
+		}
+	}
+}
+
+type storedFieldsErrorReader struct {
+	matchReader
+}
+
+func (r *storedFieldsErrorReader) VisitStoredFields(number uint64, visitor segment.StoredFieldVisitor) error {
+	return fmt.Errorf("stored fields unavailable")
+}
+
+func TestTopHitsStoredFieldsError(t *testing.T) {
+	agg := TopHits(1, nil).StoredFields("name")
+	calc := agg.Calculator().(*TopHitsCalculator)
+	d := &search.DocumentMatch{Number: 1}
+	d.SetReader(&storedFieldsErrorReader{})
+	calc.Consume(d)
+	calc.Finish()
+	hits, err := calc.Hits()
+	if err == nil {
+		t.Errorf("expected the error loading the stored fields")
+	}
+	if hits != nil {
+		t.Errorf("expected no hits, got %v", hits)
+	}
+}
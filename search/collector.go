@@ -35,3 +35,11 @@ type DocumentMatchIterator interface {
 	Next() (*DocumentMatch, error)
 	Aggregations() *Bucket
 }
+
+// PartialDocumentMatchIterator is implemented by iterators whose
+// results may have been cut short, such as by a search timeout
+type PartialDocumentMatchIterator interface {
+	DocumentMatchIterator
+	Partial() bool
+	DocumentsExamined() int
+}
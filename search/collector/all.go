@@ -17,7 +17,7 @@ package collector
 import (
 	"context"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type AllCollector struct {
@@ -97,7 +97,7 @@ func (a *AllIterator) Next() (next *search.DocumentMatch, err error) {
 	if next == nil {
 		a.bucket.Finish()
 		a.doneCleanup()
-		return nil, nil
+		return nil, a.bucket.Err()
 	}
 
 	a.hitNumber++
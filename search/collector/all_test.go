@@ -18,8 +18,8 @@ import (
 	"context"
 	"testing"
 
-	"github.com/blugelabs/bluge/search"
-	"github.com/blugelabs/bluge/search/aggregations"
+	"github.com/strivewrt/bluge/search"
+	"github.com/strivewrt/bluge/search/aggregations"
 )
 
 func TestAllCollector(t *testing.T) {
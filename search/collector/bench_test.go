@@ -19,9 +19,9 @@ import (
 	"math/rand"
 	"testing"
 
-	"github.com/blugelabs/bluge/search/aggregations"
+	"github.com/strivewrt/bluge/search/aggregations"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type createCollector func() search.Collector
@@ -15,7 +15,7 @@
 package collector
 
 import (
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type TopNIterator struct {
@@ -23,6 +23,9 @@ type TopNIterator struct {
 	bucket  *search.Bucket
 	index   int
 	err     error
+
+	partial  bool
+	examined int
 }
 
 func (i *TopNIterator) Next() (*search.DocumentMatch, error) {
@@ -40,3 +43,15 @@ func (i *TopNIterator) Next() (*search.DocumentMatch, error) {
 func (i *TopNIterator) Aggregations() *search.Bucket {
 	return i.bucket
 }
+
+// Partial returns true if collection stopped before all matches
+// were examined, for example because the search deadline passed
+func (i *TopNIterator) Partial() bool {
+	return i.partial
+}
+
+// DocumentsExamined returns the number of matches which were
+// considered when building these results
+func (i *TopNIterator) DocumentsExamined() int {
+	return i.examined
+}
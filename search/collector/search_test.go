@@ -15,7 +15,7 @@
 package collector
 
 import (
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type stubSearcher struct {
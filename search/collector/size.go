@@ -25,8 +25,11 @@ func init() {
 	sizeOfString = int(reflect.TypeOf(str).Size())
 	var coll TopNCollector
 	reflectStaticSizeTopNCollector = int(reflect.TypeOf(coll).Size())
+	var stream StreamCollector
+	reflectStaticSizeStreamCollector = int(reflect.TypeOf(stream).Size())
 }
 
 var sizeOfPtr int
 var sizeOfString int
 var reflectStaticSizeTopNCollector int
+var reflectStaticSizeStreamCollector int
@@ -0,0 +1,123 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+
+	"github.com/strivewrt/bluge/search"
+)
+
+// StreamVisitor is invoked for each match, the match is recycled
+// once the visitor returns, so it must not be retained
+type StreamVisitor func(match *search.DocumentMatch) error
+
+// StreamCollector hands every match to a visitor in the order produced
+// by the searcher (document number order), without retaining any of them.
+// Memory use is independent of the number of matches.
+type StreamCollector struct {
+	visitor      StreamVisitor
+	neededFields []string
+}
+
+// NewStreamCollector builds a collector invoking the visitor for each match,
+// after loading the document values for the specified fields
+func NewStreamCollector(visitor StreamVisitor, docValueFields ...string) *StreamCollector {
+	return &StreamCollector{
+		visitor:      visitor,
+		neededFields: docValueFields,
+	}
+}
+
+func (s *StreamCollector) Collect(ctx context.Context, aggs search.Aggregations,
+	searcher search.Collectible) (search.DocumentMatchIterator, error) {
+	// ensure that we always close the searcher
+	defer func() {
+		_ = searcher.Close()
+	}()
+
+	neededFields := dedupeFields(append(append([]string(nil), s.neededFields...), aggs.Fields()...))
+	bucket := search.NewBucket("", aggs)
+	searchContext := search.NewSearchContext(searcher.DocumentMatchPoolSize()+1, 0)
+
+	var hitNumber int
+	for {
+		if hitNumber%CheckDoneEvery == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+		}
+
+		next, err := searcher.Next(searchContext)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+
+		hitNumber++
+		next.HitNumber = hitNumber
+
+		if len(neededFields) > 0 {
+			err = next.LoadDocumentValues(searchContext, neededFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+		bucket.Consume(next)
+
+		err = s.visitor(next)
+		if err != nil {
+			return nil, err
+		}
+		searchContext.DocumentMatchPool.Put(next)
+	}
+
+	bucket.Finish()
+	if err := bucket.Err(); err != nil {
+		return nil, err
+	}
+
+	return &TopNIterator{
+		bucket:   bucket,
+		examined: hitNumber,
+	}, nil
+}
+
+func (s *StreamCollector) Size() int {
+	return reflectStaticSizeStreamCollector
+}
+
+func (s *StreamCollector) BackingSize() int {
+	return 0
+}
+
+func dedupeFields(fields []string) []string {
+	if len(fields) <= 1 {
+		return fields
+	}
+	seen := make(map[string]struct{}, len(fields))
+	rv := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := seen[field]; !ok {
+			seen[field] = struct{}{}
+			rv = append(rv, field)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,80 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/strivewrt/bluge/search"
+	"github.com/strivewrt/bluge/search/aggregations"
+)
+
+func TestStreamCollector(t *testing.T) {
+	searcher := &stubSearcher{
+		matches: makeMatches(2500, 1),
+	}
+
+	aggs := make(search.Aggregations)
+	aggs.Add("count", aggregations.CountMatches())
+
+	var visited []uint64
+	collector := NewStreamCollector(func(match *search.DocumentMatch) error {
+		visited = append(visited, match.Number)
+		return nil
+	})
+	dmi, err := collector.Collect(context.Background(), aggs, searcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 2500 {
+		t.Fatalf("expected 2500 matches visited, got %d", len(visited))
+	}
+	for i, number := range visited {
+		if number != uint64(i+1) {
+			t.Fatalf("expected match %d to be number %d, got %d", i, i+1, number)
+		}
+	}
+	if count := dmi.Aggregations().Count(); count != 2500 {
+		t.Errorf("expected count 2500, got %d", count)
+	}
+	next, err := dmi.Next()
+	if err != nil || next != nil {
+		t.Errorf("expected no retained matches, got %v, %v", next, err)
+	}
+}
+
+func TestStreamCollectorVisitorError(t *testing.T) {
+	searcher := &stubSearcher{
+		matches: makeMatches(10, 1),
+	}
+
+	var visited int
+	collector := NewStreamCollector(func(match *search.DocumentMatch) error {
+		visited++
+		if visited == 3 {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	})
+	_, err := collector.Collect(context.Background(), nil, searcher)
+	if err == nil {
+		t.Fatalf("expected visitor error")
+	}
+	if visited != 3 {
+		t.Errorf("expected 3 matches visited, got %d", visited)
+	}
+}
@@ -16,8 +16,9 @@ package collector
 
 import (
 	"context"
+	"time"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 type collectorStore interface {
@@ -52,6 +53,8 @@ type TopNCollector struct {
 
 	lowestMatchOutsideResults *search.DocumentMatch
 	searchAfter               *search.DocumentMatch
+
+	deadline time.Time
 }
 
 // CheckDoneEvery controls how frequently we check the context deadline
@@ -110,6 +113,14 @@ func newTopNCollector(size, skip int, sort search.SortOrder, reverse bool) *TopN
 	return hc
 }
 
+// SetDeadline sets a point in time after which the collector stops
+// consuming matches and returns the results collected so far, flagging
+// them as partial.  Unlike context cancellation, this is not an error.
+func (hc *TopNCollector) SetDeadline(deadline time.Time) *TopNCollector {
+	hc.deadline = deadline
+	return hc
+}
+
 func (hc *TopNCollector) Size() int {
 	sizeInBytes := reflectStaticSizeTopNCollector + sizeOfPtr
 
@@ -154,6 +165,7 @@ func (hc *TopNCollector) Collect(ctx context.Context, aggs search.Aggregations,
 	bucket := search.NewBucket("", aggs)
 
 	var hitNumber int
+	var partial bool
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -167,6 +179,11 @@ func (hc *TopNCollector) Collect(ctx context.Context, aggs search.Aggregations,
 				return nil, ctx.Err()
 			default:
 			}
+			if hc.deadlineExceeded() {
+				searchContext.DocumentMatchPool.Put(next)
+				partial = true
+				break
+			}
 		}
 
 		hitNumber++
@@ -184,6 +201,9 @@ func (hc *TopNCollector) Collect(ctx context.Context, aggs search.Aggregations,
 	}
 
 	bucket.Finish()
+	if err = bucket.Err(); err != nil {
+		return nil, err
+	}
 
 	// finalize actual results
 	err = hc.finalizeResults()
@@ -192,14 +212,20 @@ func (hc *TopNCollector) Collect(ctx context.Context, aggs search.Aggregations,
 	}
 
 	rv := &TopNIterator{
-		results: hc.results,
-		bucket:  bucket,
-		index:   0,
-		err:     nil,
+		results:  hc.results,
+		bucket:   bucket,
+		index:    0,
+		err:      nil,
+		partial:  partial,
+		examined: hitNumber,
 	}
 	return rv, nil
 }
 
+func (hc *TopNCollector) deadlineExceeded() bool {
+	return !hc.deadline.IsZero() && time.Now().After(hc.deadline)
+}
+
 func (hc *TopNCollector) collectSingle(ctx *search.Context, d *search.DocumentMatch, bucket *search.Bucket) error {
 	var err error
 
@@ -18,10 +18,11 @@ import (
 	"context"
 	"math"
 	"testing"
+	"time"
 
-	"github.com/blugelabs/bluge/search/aggregations"
+	"github.com/strivewrt/bluge/search/aggregations"
 
-	"github.com/blugelabs/bluge/search"
+	"github.com/strivewrt/bluge/search"
 )
 
 func makeMatches(n int, score float64) (rv []*search.DocumentMatch) {
@@ -293,6 +294,79 @@ func TestPaginationSameScores(t *testing.T) {
 	}
 }
 
+type slowStubSearcher struct {
+	stubSearcher
+	slowAfter int
+	delay     time.Duration
+}
+
+func (ss *slowStubSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if ss.index == ss.slowAfter {
+		time.Sleep(ss.delay)
+	}
+	return ss.stubSearcher.Next(ctx)
+}
+
+func TestTopNDeadlinePartialResults(t *testing.T) {
+	matches := makeMatches(3*CheckDoneEvery, 1)
+	matches[5].Score = 5
+	searcher := &slowStubSearcher{
+		stubSearcher: stubSearcher{
+			matches: matches,
+		},
+		slowAfter: 10,
+		delay:     20 * time.Millisecond,
+	}
+
+	aggs := make(search.Aggregations)
+	aggs.Add("count", aggregations.CountMatches())
+
+	collector := NewTopNCollector(3, 0, search.SortOrder{search.SortBy(search.DocumentScore()).Desc()}).
+		SetDeadline(time.Now().Add(10 * time.Millisecond))
+	dmi, err := collector.Collect(context.Background(), aggs, searcher)
+	if err != nil {
+		t.Fatalf("expected partial results, got error: %v", err)
+	}
+
+	pdmi, ok := dmi.(search.PartialDocumentMatchIterator)
+	if !ok {
+		t.Fatalf("expected partial document match iterator, got %T", dmi)
+	}
+	if !pdmi.Partial() {
+		t.Errorf("expected results to be flagged partial")
+	}
+	if pdmi.DocumentsExamined() != CheckDoneEvery {
+		t.Errorf("expected %d documents examined, got %d", CheckDoneEvery, pdmi.DocumentsExamined())
+	}
+	if count := dmi.Aggregations().Count(); count != CheckDoneEvery {
+		t.Errorf("expected count aggregation %d, got %d", CheckDoneEvery, count)
+	}
+
+	first, err := dmi.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == nil || first.Number != 6 {
+		t.Errorf("expected first hit to be number 6, got %v", first)
+	}
+
+	// without a deadline the same search examines everything
+	searcher.index = 0
+	searcher.delay = 0
+	collector = NewTopNCollector(3, 0, search.SortOrder{search.SortBy(search.DocumentScore()).Desc()})
+	dmi, err = collector.Collect(context.Background(), aggs, searcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdmi = dmi.(search.PartialDocumentMatchIterator)
+	if pdmi.Partial() {
+		t.Errorf("expected complete results")
+	}
+	if pdmi.DocumentsExamined() != len(matches) {
+		t.Errorf("expected %d documents examined, got %d", len(matches), pdmi.DocumentsExamined())
+	}
+}
+
 func BenchmarkTop10of0Scores(b *testing.B) {
 	benchHelper(0, func() search.Collector {
 		return NewTopNCollector(10, 0, search.SortOrder{search.SortBy(search.DocumentScore()).Desc()})
@@ -0,0 +1,268 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"time"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/numeric/geo"
+)
+
+// FieldType describes how the stored value of a field is encoded
+type FieldType byte
+
+const (
+	// FieldTypeUnknown is used for fields indexed without type metadata
+	FieldTypeUnknown FieldType = iota
+	FieldTypeText
+	FieldTypeBytes
+	FieldTypeNumeric
+	FieldTypeDateTime
+	FieldTypeGeoPoint
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case FieldTypeText:
+		return "text"
+	case FieldTypeBytes:
+		return "bytes"
+	case FieldTypeNumeric:
+		return "numeric"
+	case FieldTypeDateTime:
+		return "datetime"
+	case FieldTypeGeoPoint:
+		return "geopoint"
+	}
+	return "unknown"
+}
+
+// StoredFieldTypesVisitable is implemented by readers which can visit the
+// types recorded for the stored fields of a document along with their values
+type StoredFieldTypesVisitable interface {
+	VisitStoredFieldsWithTypes(number uint64, visitor segment.StoredFieldVisitor,
+		typeVisitor func(field string, typ byte)) error
+}
+
+// readerUnwrapper is implemented by readers wrapping another reader
+type readerUnwrapper interface {
+	Unwrap() Reader
+}
+
+// storedFieldTypesVisitable finds the reader able to visit stored field
+// types, looking through any wrapping readers
+func storedFieldTypesVisitable(reader StoredFieldVisitable) StoredFieldTypesVisitable {
+	for reader != nil {
+		if typed, ok := reader.(StoredFieldTypesVisitable); ok {
+			return typed
+		}
+		wrapper, ok := reader.(readerUnwrapper)
+		if !ok {
+			return nil
+		}
+		reader = wrapper.Unwrap()
+	}
+	return nil
+}
+
+// StoredDocument is a typed view of the stored fields of a document
+type StoredDocument struct {
+	number uint64
+	fields []string
+	values map[string][][]byte
+	types  map[string]FieldType
+}
+
+// LoadStoredDocument builds a StoredDocument from the stored fields of
+// the document in the reader, keeping only the listed fields, or all of
+// them if none are listed
+func LoadStoredDocument(reader StoredFieldVisitable, number uint64, fields ...string) (*StoredDocument, error) {
+	var include map[string]struct{}
+	if len(fields) > 0 {
+		include = make(map[string]struct{}, len(fields))
+		for _, field := range fields {
+			include[field] = struct{}{}
+		}
+	}
+	rv := &StoredDocument{
+		number: number,
+		values: make(map[string][][]byte),
+		types:  make(map[string]FieldType),
+	}
+	visitor := func(field string, value []byte) bool {
+		if include != nil {
+			if _, ok := include[field]; !ok {
+				return true
+			}
+		}
+		if _, ok := rv.values[field]; !ok {
+			rv.fields = append(rv.fields, field)
+		}
+		valueCopy := make([]byte, len(value))
+		copy(valueCopy, value)
+		rv.values[field] = append(rv.values[field], valueCopy)
+		return true
+	}
+	var err error
+	if typed := storedFieldTypesVisitable(reader); typed != nil {
+		err = typed.VisitStoredFieldsWithTypes(number, visitor, func(field string, typ byte) {
+			rv.types[field] = FieldType(typ)
+		})
+	} else {
+		err = reader.VisitStoredFields(number, visitor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Document returns a typed view of the stored fields of the match,
+// limited to the listed fields, or all of them if none are listed
+func (dm *DocumentMatch) Document(fields ...string) (*StoredDocument, error) {
+	return LoadStoredDocument(dm.reader, dm.Number, fields...)
+}
+
+// DocumentNumber returns the number of the document in the reader
+func (d *StoredDocument) DocumentNumber() uint64 {
+	return d.number
+}
+
+// Fields returns the names of the stored fields, in the order visited
+func (d *StoredDocument) Fields() []string {
+	return d.fields
+}
+
+// Type returns the type recorded for the field when it was indexed
+func (d *StoredDocument) Type(field string) FieldType {
+	return d.types[field]
+}
+
+// ID returns the identifier of the document
+func (d *StoredDocument) ID() string {
+	return d.Text("_id")
+}
+
+func (d *StoredDocument) Value(field string) []byte {
+	return firstTerm(d.values[field])
+}
+
+func (d *StoredDocument) Values(field string) [][]byte {
+	return d.values[field]
+}
+
+func (d *StoredDocument) Text(field string) string {
+	return string(d.Value(field))
+}
+
+func (d *StoredDocument) Texts(field string) []string {
+	values := d.values[field]
+	if len(values) == 0 {
+		return nil
+	}
+	rv := make([]string, len(values))
+	for i, value := range values {
+		rv[i] = string(value)
+	}
+	return rv
+}
+
+// Number returns the first numeric value of the field, or NaN
+func (d *StoredDocument) Number(field string) float64 {
+	return firstNumber(d.Numbers(field))
+}
+
+// Numbers returns the values of the field which decode as numbers,
+// none if the field was recorded with another type
+func (d *StoredDocument) Numbers(field string) []float64 {
+	var rv []float64
+	for _, i64 := range d.decodeInt64s(field, FieldTypeNumeric) {
+		rv = append(rv, numeric.Int64ToFloat64(i64))
+	}
+	return rv
+}
+
+// Date returns the first date value of the field, or the zero time
+func (d *StoredDocument) Date(field string) time.Time {
+	return firstDate(d.Dates(field))
+}
+
+// Dates returns the values of the field which decode as dates,
+// none if the field was recorded with another type
+func (d *StoredDocument) Dates(field string) []time.Time {
+	var rv []time.Time
+	for _, i64 := range d.decodeInt64s(field, FieldTypeDateTime) {
+		rv = append(rv, time.Unix(0, i64).UTC())
+	}
+	return rv
+}
+
+// GeoPoint returns the first geo point value of the field, or nil
+func (d *StoredDocument) GeoPoint(field string) *geo.Point {
+	return firstGeoPoint(d.GeoPoints(field))
+}
+
+// GeoPoints returns the values of the field which decode as geo points,
+// none if the field was recorded with another type
+func (d *StoredDocument) GeoPoints(field string) []*geo.Point {
+	var rv []*geo.Point
+	for _, i64 := range d.decodeInt64s(field, FieldTypeGeoPoint) {
+		rv = append(rv, &geo.Point{
+			Lon: geo.MortonUnhashLon(uint64(i64)),
+			Lat: geo.MortonUnhashLat(uint64(i64)),
+		})
+	}
+	return rv
+}
+
+// Interface returns the first value of the field
+// converted according to its recorded type
+func (d *StoredDocument) Interface(field string) interface{} {
+	switch d.types[field] {
+	case FieldTypeText:
+		return d.Text(field)
+	case FieldTypeNumeric:
+		return d.Number(field)
+	case FieldTypeDateTime:
+		return d.Date(field)
+	case FieldTypeGeoPoint:
+		return d.GeoPoint(field)
+	}
+	return d.Value(field)
+}
+
+// decodeInt64s decodes the prefix coded values of the field, unless
+// it was recorded with a type other than the one expected
+func (d *StoredDocument) decodeInt64s(field string, typ FieldType) []int64 {
+	if recorded := d.types[field]; recorded != FieldTypeUnknown && recorded != typ {
+		return nil
+	}
+	var rv []int64
+	for _, value := range d.values[field] {
+		prefixCoded := numeric.PrefixCoded(value)
+		shift, err := prefixCoded.Shift()
+		if err != nil || shift != 0 {
+			continue
+		}
+		i64, err := prefixCoded.Int64()
+		if err == nil {
+			rv = append(rv, i64)
+		}
+	}
+	return rv
+}
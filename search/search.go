@@ -107,6 +107,11 @@ func (dm *DocumentMatch) SetReader(r MatchReader) {
 	dm.reader = r
 }
 
+// Reader returns the reader the document was matched in
+func (dm *DocumentMatch) Reader() MatchReader {
+	return dm.reader
+}
+
 func (dm *DocumentMatch) addDocValue(name string, value []byte) {
 	if dm.docValues == nil {
 		dm.docValues = make(map[string][][]byte)
@@ -261,6 +266,31 @@ type Searcher interface {
 	DocumentMatchPoolSize() int
 }
 
+// SearcherWrapper is implemented by searchers decorating another searcher,
+// such as to measure it, Unwrap returns the decorated searcher
+type SearcherWrapper interface {
+	Unwrap() Searcher
+}
+
+// ParentSearcher is implemented by searchers combining the matches of other
+// searchers.  WrapChildren replaces each child with the result of wrap, it
+// must be called before the searcher is first advanced.
+type ParentSearcher interface {
+	Children() []Searcher
+	WrapChildren(wrap func(Searcher) Searcher)
+}
+
+// UnwrapSearcher returns the searcher decorated by any searcher wrappers
+func UnwrapSearcher(s Searcher) Searcher {
+	for {
+		w, ok := s.(SearcherWrapper)
+		if !ok {
+			return s
+		}
+		s = w.Unwrap()
+	}
+}
+
 type SearcherOptions struct {
 	SimilarityForField func(field string) Similarity
 	DefaultSearchField string
@@ -356,6 +386,13 @@ type Reader interface {
 	Close() error
 }
 
+// TermStatsReader may optionally be implemented by a Reader to supply the
+// document frequency used when scoring a term, in place of the one observed
+// in its own postings, for example statistics merged across several indexes.
+type TermStatsReader interface {
+	DocumentFrequency(field string, term []byte) (uint64, bool)
+}
+
 type Similarity interface {
 	ComputeNorm(numTerms int) float32
 	Scorer(boost float64, collectionStats segment.CollectionStats, termStats segment.TermStats) Scorer
@@ -47,6 +47,30 @@ func NewBooleanSearcher(mustSearcher, shouldSearcher, mustNotSearcher search.Sea
 	return &rv, nil
 }
 
+// Clauses returns the searchers for the must, should and must not clauses,
+// any of which may be nil
+func (s *BooleanSearcher) Clauses() (must, should, mustNot search.Searcher) {
+	return s.mustSearcher, s.shouldSearcher, s.mustNotSearcher
+}
+
+func (s *BooleanSearcher) Children() []search.Searcher {
+	var rv []search.Searcher
+	for _, child := range []search.Searcher{s.mustSearcher, s.shouldSearcher, s.mustNotSearcher} {
+		if child != nil {
+			rv = append(rv, child)
+		}
+	}
+	return rv
+}
+
+func (s *BooleanSearcher) WrapChildren(wrap func(search.Searcher) search.Searcher) {
+	for _, child := range []*search.Searcher{&s.mustSearcher, &s.shouldSearcher, &s.mustNotSearcher} {
+		if *child != nil {
+			*child = wrap(*child)
+		}
+	}
+}
+
 func (s *BooleanSearcher) Size() int {
 	sizeInBytes := reflectStaticSizeBooleanSearcher + sizeOfPtr
 
@@ -68,6 +68,16 @@ func NewConjunctionSearcher(indexReader search.Reader,
 	return &rv, nil
 }
 
+func (s *ConjunctionSearcher) Children() []search.Searcher {
+	return s.searchers
+}
+
+func (s *ConjunctionSearcher) WrapChildren(wrap func(search.Searcher) search.Searcher) {
+	for i, child := range s.searchers {
+		s.searchers[i] = wrap(child)
+	}
+}
+
 func (s *ConjunctionSearcher) Size() int {
 	sizeInBytes := reflectStaticSizeConjunctionSearcher + sizeOfPtr
 
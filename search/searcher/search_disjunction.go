@@ -96,8 +96,13 @@ func optimizeCompositeSearcher(optimizationKind string,
 		return nil, err
 	}
 
-	return newTermSearcherFromReader(indexReader, optimized,
+	rv, err := newTermSearcherFromReader(indexReader, optimized,
 		[]byte(optimizationKind), "*", 1.0, similarity.ConstantScorer(1), options)
+	if err != nil {
+		return nil, err
+	}
+	rv.optimization = optimizationKind
+	return rv, nil
 }
 
 func tooManyClauses(count int) bool {
@@ -61,6 +61,19 @@ func newDisjunctionHeapSearcher(searchers []search.Searcher, min int, scorer sea
 	return &rv, nil
 }
 
+func (s *DisjunctionHeapSearcher) Children() []search.Searcher {
+	return s.searchers
+}
+
+func (s *DisjunctionHeapSearcher) WrapChildren(wrap func(search.Searcher) search.Searcher) {
+	// the searchers are shared with the caller which built this searcher
+	searchers := make([]search.Searcher, len(s.searchers))
+	for i, child := range s.searchers {
+		searchers[i] = wrap(child)
+	}
+	s.searchers = searchers
+}
+
 func (s *DisjunctionHeapSearcher) Size() int {
 	sizeInBytes := reflectStaticSizeDisjunctionHeapSearcher + sizeOfPtr
 
@@ -59,6 +59,16 @@ func newDisjunctionSliceSearcher(qsearchers []search.Searcher, min int, scorer s
 	return &rv, nil
 }
 
+func (s *DisjunctionSliceSearcher) Children() []search.Searcher {
+	return s.searchers
+}
+
+func (s *DisjunctionSliceSearcher) WrapChildren(wrap func(search.Searcher) search.Searcher) {
+	for i, child := range s.searchers {
+		s.searchers[i] = wrap(child)
+	}
+}
+
 func (s *DisjunctionSliceSearcher) Size() int {
 	sizeInBytes := reflectStaticSizeDisjunctionSliceSearcher + sizeOfPtr
 
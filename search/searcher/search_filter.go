@@ -37,6 +37,14 @@ func NewFilteringSearcher(s search.Searcher, filter FilterFunc) *FilteringSearch
 	}
 }
 
+func (f *FilteringSearcher) Children() []search.Searcher {
+	return []search.Searcher{f.child}
+}
+
+func (f *FilteringSearcher) WrapChildren(wrap func(search.Searcher) search.Searcher) {
+	f.child = wrap(f.child)
+}
+
 func (f *FilteringSearcher) Size() int {
 	return reflectStaticSizeFilteringSearcher + sizeOfPtr +
 		f.child.Size()
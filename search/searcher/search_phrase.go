@@ -33,6 +33,14 @@ type PhraseSearcher struct {
 	slop         int
 }
 
+func (s *PhraseSearcher) Children() []search.Searcher {
+	return []search.Searcher{s.mustSearcher}
+}
+
+func (s *PhraseSearcher) WrapChildren(wrap func(search.Searcher) search.Searcher) {
+	s.mustSearcher = wrap(s.mustSearcher)
+}
+
 func (s *PhraseSearcher) Size() int {
 	sizeInBytes := reflectStaticSizePhraseSearcher + sizeOfPtr
 
@@ -25,6 +25,8 @@ type TermSearcher struct {
 	options     search.SearcherOptions
 	scorer      search.Scorer
 	queryTerm   string
+
+	optimization string
 }
 
 func NewTermSearcher(indexReader search.Reader, term, field string, boost float64, scorer search.Scorer,
@@ -42,6 +44,13 @@ func NewTermSearcherBytes(indexReader search.Reader, term []byte, field string,
 	return newTermSearcherFromReader(indexReader, reader, term, field, boost, scorer, options)
 }
 
+// NewPostingsSearcher returns a searcher over the documents of an arbitrary
+// postings iterator, every match is scored by the provided scorer
+func NewPostingsSearcher(indexReader search.Reader, postings segment.PostingsIterator, scorer search.Scorer,
+	options search.SearcherOptions) (*TermSearcher, error) {
+	return newTermSearcherFromReader(indexReader, postings, nil, "*", 1.0, scorer, options)
+}
+
 type termStatsWrapper struct {
 	docFreq uint64
 }
@@ -57,7 +66,13 @@ func newTermSearcherFromReader(indexReader search.Reader, reader segment.Posting
 		if err != nil {
 			return nil, err
 		}
-		scorer = options.SimilarityForField(field).Scorer(boost, collStats, &termStatsWrapper{docFreq: reader.Count()})
+		docFreq := reader.Count()
+		if tsr, ok := indexReader.(search.TermStatsReader); ok {
+			if globalDocFreq, ok := tsr.DocumentFrequency(field, term); ok {
+				docFreq = globalDocFreq
+			}
+		}
+		scorer = options.SimilarityForField(field).Scorer(boost, collStats, &termStatsWrapper{docFreq: docFreq})
 	}
 	return &TermSearcher{
 		indexReader: indexReader,
@@ -72,6 +87,12 @@ func (s *TermSearcher) Size() int {
 	return reflectStaticSizeTermSearcher + sizeOfPtr + s.reader.Size()
 }
 
+// Optimization returns the kind of composite optimization which built
+// this searcher in place of several others, or the empty string
+func (s *TermSearcher) Optimization() string {
+	return s.optimization
+}
+
 func (s *TermSearcher) Count() uint64 {
 	return s.reader.Count()
 }
@@ -51,6 +51,22 @@ func (o SortOrder) Compute(match *DocumentMatch) {
 }
 
 func (o SortOrder) Compare(i, j *DocumentMatch) int {
+	c := o.CompareValues(i, j)
+	if c != 0 {
+		return c
+	}
+	// if they are the same at this point, impose order based on index natural sort order
+	if i.HitNumber == j.HitNumber {
+		return 0
+	} else if i.HitNumber > j.HitNumber {
+		return 1
+	}
+	return -1
+}
+
+// CompareValues compares the documents on the sort values only,
+// without breaking ties by their order in the index
+func (o SortOrder) CompareValues(i, j *DocumentMatch) int {
 	// compare the documents on all search sorts until a differences is found
 	for x := range o {
 		c := 0
@@ -66,13 +82,7 @@ func (o SortOrder) Compare(i, j *DocumentMatch) int {
 		}
 		return c
 	}
-	// if they are the same at this point, impose order based on index natural sort order
-	if i.HitNumber == j.HitNumber {
-		return 0
-	} else if i.HitNumber > j.HitNumber {
-		return 1
-	}
-	return -1
+	return 0
 }
 
 type SortValue [][]byte
@@ -112,6 +122,11 @@ func (s *Sort) Value(match *DocumentMatch) []byte {
 	return s.source.Value(match)
 }
 
+// ParseSearchSortString parses a sort of the form [-+]field[:mode[:type]],
+// a leading - sorts in descending order.  The optional mode (min, max, sum,
+// avg or median) selects how the values of a multi-valued field are
+// reduced, the type (number, date or text) selects how they are interpreted
+// and defaults to number, min and max also apply to text without the type.
 func ParseSearchSortString(input string) *Sort {
 	descending := false
 	if strings.HasPrefix(input, "-") {
@@ -122,13 +137,47 @@ func ParseSearchSortString(input string) *Sort {
 	if input == "_score" {
 		return SortBy(&ScoreSource{}).Desc()
 	}
-	rv := SortBy(Field(input))
+	rv := SortBy(parseSortSource(input))
 	if descending {
 		rv.Desc()
 	}
 	return rv
 }
 
+func parseSortSource(input string) TextValueSource {
+	parts := strings.Split(input, ":")
+	if len(parts) >= 3 {
+		field := strings.Join(parts[:len(parts)-2], ":")
+		mode, err := ParseSortMode(parts[len(parts)-2])
+		if err == nil {
+			switch parts[len(parts)-1] {
+			case "number":
+				return NumericSortValue(Field(field), mode)
+			case "date":
+				return DateSortValue(Field(field), mode)
+			case "text":
+				return TextSortValue(Field(field), mode)
+			}
+		}
+	}
+	if len(parts) >= 2 {
+		field := strings.Join(parts[:len(parts)-1], ":")
+		mode, err := ParseSortMode(parts[len(parts)-1])
+		if err == nil {
+			switch mode {
+			case SortModeFirst:
+				return Field(field)
+			case SortModeMin, SortModeMax:
+				return TextSortValue(Field(field), mode)
+			default:
+				return NumericSortValue(Field(field), mode)
+			}
+		}
+	}
+	// not a recognized mode, the colons are part of the field name
+	return Field(input)
+}
+
 func ParseSortOrderStrings(in []string) SortOrder {
 	rv := make(SortOrder, 0, len(in))
 	for _, i := range in {
@@ -0,0 +1,258 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/blugelabs/bluge/numeric"
+)
+
+// SortMode controls how the values of a multi-valued field
+// are reduced to the single value a document is sorted by
+type SortMode int
+
+const (
+	// SortModeFirst uses the first value in the doc values,
+	// which is the default when sorting by a field
+	SortModeFirst SortMode = iota
+	SortModeMin
+	SortModeMax
+	SortModeSum
+	SortModeAvg
+	SortModeMedian
+)
+
+var sortModeNames = map[SortMode]string{
+	SortModeFirst:  "first",
+	SortModeMin:    "min",
+	SortModeMax:    "max",
+	SortModeSum:    "sum",
+	SortModeAvg:    "avg",
+	SortModeMedian: "median",
+}
+
+func (m SortMode) String() string {
+	if name, ok := sortModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("SortMode(%d)", int(m))
+}
+
+// ParseSortMode returns the sort mode with the provided name
+func ParseSortMode(name string) (SortMode, error) {
+	for mode, modeName := range sortModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return SortModeFirst, fmt.Errorf("unknown sort mode '%s'", name)
+}
+
+// TextSortValueSource sorts by the smallest or largest value of a
+// multi-valued field, comparing the values as bytes.  Numeric and date
+// values are encoded such that this is also their natural order.
+// Documents without values return nil, so that they are placed according
+// to the missing value placement of the sort.
+type TextSortValueSource struct {
+	source TextValuesSource
+	mode   SortMode
+}
+
+// TextSortValue reduces the values of the source using the mode,
+// only SortModeMin and SortModeMax apply to text, any other mode
+// uses the first value
+func TextSortValue(source TextValuesSource, mode SortMode) *TextSortValueSource {
+	return &TextSortValueSource{
+		source: source,
+		mode:   mode,
+	}
+}
+
+func (t *TextSortValueSource) Fields() []string {
+	return t.source.Fields()
+}
+
+func (t *TextSortValueSource) Value(match *DocumentMatch) []byte {
+	values := RemoveNumericPaddedTerms(t.source.Values(match))
+	if len(values) == 0 {
+		return nil
+	}
+	rv := values[0]
+	for _, value := range values[1:] {
+		switch t.mode {
+		case SortModeMin:
+			if bytes.Compare(value, rv) < 0 {
+				rv = value
+			}
+		case SortModeMax:
+			if bytes.Compare(value, rv) > 0 {
+				rv = value
+			}
+		}
+	}
+	return rv
+}
+
+// NumericSortValueSource sorts by a single number computed
+// from the values of a multi-valued numeric field
+type NumericSortValueSource struct {
+	source NumericValuesSource
+	mode   SortMode
+}
+
+// NumericSortValue reduces the values of the source using the mode
+func NumericSortValue(source NumericValuesSource, mode SortMode) *NumericSortValueSource {
+	return &NumericSortValueSource{
+		source: source,
+		mode:   mode,
+	}
+}
+
+func (n *NumericSortValueSource) Fields() []string {
+	return n.source.Fields()
+}
+
+func (n *NumericSortValueSource) Value(match *DocumentMatch) []byte {
+	number, ok := n.number(match)
+	if !ok {
+		return nil
+	}
+	return numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(number), 0)
+}
+
+func (n *NumericSortValueSource) Number(match *DocumentMatch) float64 {
+	number, ok := n.number(match)
+	if !ok {
+		return math.NaN()
+	}
+	return number
+}
+
+func (n *NumericSortValueSource) number(match *DocumentMatch) (float64, bool) {
+	values := n.source.Numbers(match)
+	if len(values) == 0 {
+		return 0, false
+	}
+	rv := values[0]
+	switch n.mode {
+	case SortModeMin:
+		for _, value := range values[1:] {
+			rv = math.Min(rv, value)
+		}
+	case SortModeMax:
+		for _, value := range values[1:] {
+			rv = math.Max(rv, value)
+		}
+	case SortModeSum, SortModeAvg:
+		for _, value := range values[1:] {
+			rv += value
+		}
+		if n.mode == SortModeAvg {
+			rv /= float64(len(values))
+		}
+	case SortModeMedian:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		mid := len(sorted) / 2
+		rv = sorted[mid]
+		if len(sorted)%2 == 0 {
+			rv = (sorted[mid-1] + sorted[mid]) / 2
+		}
+	}
+	return rv, true
+}
+
+// DateSortValueSource sorts by a single date computed
+// from the values of a multi-valued date field
+type DateSortValueSource struct {
+	source DateValuesSource
+	mode   SortMode
+}
+
+// DateSortValue reduces the values of the source using the mode,
+// dates are summed and averaged as nanoseconds since the epoch
+func DateSortValue(source DateValuesSource, mode SortMode) *DateSortValueSource {
+	return &DateSortValueSource{
+		source: source,
+		mode:   mode,
+	}
+}
+
+func (d *DateSortValueSource) Fields() []string {
+	return d.source.Fields()
+}
+
+func (d *DateSortValueSource) Value(match *DocumentMatch) []byte {
+	dates := d.source.Dates(match)
+	if len(dates) == 0 {
+		return nil
+	}
+	values := make([]int64, len(dates))
+	for i, date := range dates {
+		values[i] = date.UnixNano()
+	}
+	rv := values[0]
+	switch d.mode {
+	case SortModeMin:
+		for _, value := range values[1:] {
+			if value < rv {
+				rv = value
+			}
+		}
+	case SortModeMax:
+		for _, value := range values[1:] {
+			if value > rv {
+				rv = value
+			}
+		}
+	case SortModeSum:
+		for _, value := range values[1:] {
+			rv = saturatingAdd(rv, value)
+		}
+	case SortModeAvg:
+		// the sum of the nanoseconds may not fit in an int64, the average does
+		sum := new(big.Int)
+		for _, value := range values {
+			sum.Add(sum, big.NewInt(value))
+		}
+		rv = sum.Quo(sum, big.NewInt(int64(len(values)))).Int64()
+	case SortModeMedian:
+		sort.Slice(values, func(i, j int) bool {
+			return values[i] < values[j]
+		})
+		mid := len(values) / 2
+		rv = values[mid]
+		if len(values)%2 == 0 {
+			// the values are sorted, so their difference fits in a uint64
+			rv = values[mid-1] + int64((uint64(values[mid])-uint64(values[mid-1]))/2)
+		}
+	}
+	return numeric.MustNewPrefixCodedInt64(rv, 0)
+}
+
+func saturatingAdd(a, b int64) int64 {
+	if b > 0 && a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	if b < 0 && a < math.MinInt64-b {
+		return math.MinInt64
+	}
+	return a + b
+}
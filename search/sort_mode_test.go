@@ -0,0 +1,242 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/blugelabs/bluge/numeric"
+)
+
+func numericDoc(id string, values ...float64) *DocumentMatch {
+	rv := &DocumentMatch{}
+	rv.addDocValue("_id", []byte(id))
+	for _, value := range values {
+		rv.addDocValue("n", numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(value), 0))
+		// lower precision terms, as indexed for range queries
+		rv.addDocValue("n", numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(value), 8))
+	}
+	return rv
+}
+
+func dateDoc(id string, values ...time.Time) *DocumentMatch {
+	rv := &DocumentMatch{}
+	rv.addDocValue("_id", []byte(id))
+	for _, value := range values {
+		rv.addDocValue("d", numeric.MustNewPrefixCodedInt64(value.UnixNano(), 0))
+	}
+	return rv
+}
+
+func textDoc(id string, values ...string) *DocumentMatch {
+	rv := &DocumentMatch{}
+	rv.addDocValue("_id", []byte(id))
+	for _, value := range values {
+		rv.addDocValue("t", []byte(value))
+	}
+	return rv
+}
+
+func sortedIDs(order SortOrder, docs []*DocumentMatch) []string {
+	for i, doc := range docs {
+		doc.HitNumber = i
+		doc.SortValue = nil
+		order.Compute(doc)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return order.Compare(docs[i], docs[j]) < 0
+	})
+	rv := make([]string, len(docs))
+	for i, doc := range docs {
+		rv[i] = string(doc.DocValues("_id")[0])
+	}
+	return rv
+}
+
+func TestSortModes(t *testing.T) {
+	numericDocs := func() []*DocumentMatch {
+		return []*DocumentMatch{
+			numericDoc("a", 5, 1, 9),    // min 1, max 9, sum 15, avg 5, median 5
+			numericDoc("b", 3, 4),       // min 3, max 4, sum 7, avg 3.5, median 3.5
+			numericDoc("c"),             // missing
+			numericDoc("d", -2, 20, 10), // min -2, max 20, sum 28, avg 9.33, median 10
+		}
+	}
+	day := func(d int) time.Time {
+		return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	dateDocs := func() []*DocumentMatch {
+		return []*DocumentMatch{
+			dateDoc("a", day(10), day(2), day(30)), // min 2, max 30, avg 14, median 10
+			dateDoc("b", day(5), day(6)),           // min 5, max 6, avg 5.5, median 5.5
+			dateDoc("c"),                           // missing
+		}
+	}
+	extremeDateDocs := func() []*DocumentMatch {
+		return []*DocumentMatch{
+			dateDoc("c", day(1)),
+			// the sum and difference of the nanoseconds overflow an int64,
+			// their average is the epoch
+			dateDoc("b", time.Unix(0, math.MinInt64), time.Unix(0, math.MaxInt64)),
+			dateDoc("a", time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)),
+		}
+	}
+	textDocs := func() []*DocumentMatch {
+		return []*DocumentMatch{
+			textDoc("a", "m", "b"),
+			textDoc("b", "c", "k"),
+			textDoc("c"),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		order  SortOrder
+		docs   []*DocumentMatch
+		expect []string
+	}{
+		{
+			name:   "numeric min",
+			order:  SortOrder{SortBy(NumericSortValue(Field("n"), SortModeMin))},
+			docs:   numericDocs(),
+			expect: []string{"d", "a", "b", "c"},
+		},
+		{
+			name:   "numeric max desc",
+			order:  SortOrder{SortBy(NumericSortValue(Field("n"), SortModeMax)).Desc()},
+			docs:   numericDocs(),
+			expect: []string{"d", "a", "b", "c"},
+		},
+		{
+			name:   "numeric sum",
+			order:  SortOrder{SortBy(NumericSortValue(Field("n"), SortModeSum))},
+			docs:   numericDocs(),
+			expect: []string{"b", "a", "d", "c"},
+		},
+		{
+			name:   "numeric avg missing first",
+			order:  SortOrder{SortBy(NumericSortValue(Field("n"), SortModeAvg)).MissingFirst()},
+			docs:   numericDocs(),
+			expect: []string{"c", "b", "a", "d"},
+		},
+		{
+			name:   "numeric median",
+			order:  SortOrder{SortBy(NumericSortValue(Field("n"), SortModeMedian))},
+			docs:   numericDocs(),
+			expect: []string{"b", "a", "d", "c"},
+		},
+		{
+			name:   "date min",
+			order:  SortOrder{SortBy(DateSortValue(Field("d"), SortModeMin))},
+			docs:   dateDocs(),
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "date max",
+			order:  SortOrder{SortBy(DateSortValue(Field("d"), SortModeMax))},
+			docs:   dateDocs(),
+			expect: []string{"b", "a", "c"},
+		},
+		{
+			name:   "date median desc",
+			order:  SortOrder{SortBy(DateSortValue(Field("d"), SortModeMedian)).Desc()},
+			docs:   dateDocs(),
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "date avg extremes",
+			order:  SortOrder{SortBy(DateSortValue(Field("d"), SortModeAvg))},
+			docs:   extremeDateDocs(),
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "date median extremes",
+			order:  SortOrder{SortBy(DateSortValue(Field("d"), SortModeMedian))},
+			docs:   extremeDateDocs(),
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "text min",
+			order:  SortOrder{SortBy(TextSortValue(Field("t"), SortModeMin))},
+			docs:   textDocs(),
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "text max",
+			order:  SortOrder{SortBy(TextSortValue(Field("t"), SortModeMax))},
+			docs:   textDocs(),
+			expect: []string{"b", "a", "c"},
+		},
+		{
+			name:   "text max desc",
+			order:  SortOrder{SortBy(TextSortValue(Field("t"), SortModeMax)).Desc()},
+			docs:   textDocs(),
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "parsed numeric sum",
+			order:  ParseSortOrderStrings([]string{"n:sum"}),
+			docs:   numericDocs(),
+			expect: []string{"b", "a", "d", "c"},
+		},
+		{
+			name:   "parsed numeric min desc",
+			order:  ParseSortOrderStrings([]string{"-n:min"}),
+			docs:   numericDocs(),
+			expect: []string{"b", "a", "d", "c"},
+		},
+		{
+			name:   "parsed date avg",
+			order:  ParseSortOrderStrings([]string{"d:avg:date"}),
+			docs:   dateDocs(),
+			expect: []string{"b", "a", "c"},
+		},
+		{
+			name:   "parsed text max",
+			order:  ParseSortOrderStrings([]string{"t:max"}),
+			docs:   textDocs(),
+			expect: []string{"b", "a", "c"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := sortedIDs(test.order, test.docs)
+			if !reflect.DeepEqual(got, test.expect) {
+				t.Errorf("expected %v, got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestParseSearchSortStringField(t *testing.T) {
+	tests := map[string][]string{
+		"name":         {"name"},
+		"-name":        {"name"},
+		"a:b":          {"a:b"},
+		"a:b:max":      {"a:b"},
+		"a:b:avg:date": {"a:b"},
+	}
+	for input, expect := range tests {
+		got := ParseSearchSortString(input).Fields()
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("%s: expected fields %v, got %v", input, expect, got)
+		}
+	}
+}
@@ -296,6 +296,34 @@ func (f *FilteringTextSource) Values(match *DocumentMatch) [][]byte {
 	return rv
 }
 
+// CollationKeySource sorts the values of a text source in the order of a
+// locale, by computing their collation keys at search time with the key
+// function (such as token.CollationFilter.Key).  Fields indexed with
+// collation keys already sort this way, and do not need this source.
+type CollationKeySource struct {
+	source TextValueSource
+	key    func([]byte) []byte
+}
+
+func CollationKeys(source TextValueSource, key func([]byte) []byte) *CollationKeySource {
+	return &CollationKeySource{
+		source: source,
+		key:    key,
+	}
+}
+
+func (c *CollationKeySource) Fields() []string {
+	return c.source.Fields()
+}
+
+func (c *CollationKeySource) Value(match *DocumentMatch) []byte {
+	value := c.source.Value(match)
+	if value == nil {
+		return nil
+	}
+	return c.key(value)
+}
+
 func firstTerm(sourceValues [][]byte) []byte {
 	if len(sourceValues) > 0 {
 		return sourceValues[0]
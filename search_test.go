@@ -17,6 +17,7 @@ package bluge
 import (
 	"context"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"testing"
@@ -1380,3 +1381,435 @@ func TestBooleanSearchBoost(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSignificantTermsAggregation(t *testing.T) {
+	var docs []*Document
+	for i := 0; i < 20; i++ {
+		category := "a"
+		if i >= 10 {
+			category = "b"
+		}
+		doc := NewDocument(strconv.Itoa(i)).
+			AddField(NewKeywordField("category", category)).
+			AddField(NewKeywordField("tag", "common").Aggregatable())
+		if i < 8 {
+			doc.AddField(NewKeywordField("tag", "mostly-a").Aggregatable())
+		}
+		if i < 8 && i%2 == 0 || i == 11 {
+			doc.AddField(NewKeywordField("tag", "outage").Aggregatable())
+		}
+		if i == 1 {
+			doc.AddField(NewKeywordField("tag", "rare").Aggregatable())
+		}
+		docs = append(docs, doc)
+	}
+
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+	tmpIndexPath3 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath3)
+	tmpIndexPath4 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath4)
+	tmpIndexPath5 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath5)
+
+	// all the documents in one index, or split between two of them,
+	// with the foreground "outage" documents all in the first one, or
+	// with the second having no matching documents at all
+	var even, odd []*Document
+	for i, doc := range docs {
+		if i%2 == 0 {
+			even = append(even, doc)
+		} else {
+			odd = append(odd, doc)
+		}
+	}
+	writers := []*Writer{
+		openTestWriterWithDocs(t, tmpIndexPath, docs...),
+		openTestWriterWithDocs(t, tmpIndexPath2, even...),
+		openTestWriterWithDocs(t, tmpIndexPath3, odd...),
+		openTestWriterWithDocs(t, tmpIndexPath4, docs[:10]...),
+		openTestWriterWithDocs(t, tmpIndexPath5, docs[10:]...),
+	}
+	var readers []*Reader
+	for _, writer := range writers {
+		reader, err := writer.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, reader)
+	}
+	defer func() {
+		for i := range writers {
+			_ = readers[i].Close()
+			_ = writers[i].Close()
+		}
+	}()
+
+	for i, searchReaders := range [][]*Reader{readers[:1], readers[1:3], readers[3:], readers[3:]} {
+		req := NewTopNSearch(10, NewTermQuery("a").SetField("category"))
+		req.AddAggregation("significant", aggregations.SignificantTerms("tag", 10))
+		dmi, err := MultiSearchWithOptions(context.Background(), req, MultiSearchOptions{
+			Parallel: i == 3,
+		}, searchReaders...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calc := dmi.Aggregations().Aggregation("significant").(*aggregations.SignificantTermsCalculator)
+		var terms []string
+		for _, bucket := range calc.Buckets() {
+			terms = append(terms, bucket.Name())
+		}
+		if len(terms) != 2 || terms[0] != "mostly-a" || terms[1] != "outage" {
+			t.Fatalf("%d readers: expected terms [mostly-a outage], got %v", len(searchReaders), terms)
+		}
+		if calc.SubsetSize() != 10 || calc.SupersetSize() != 20 {
+			t.Errorf("%d readers: expected subset 10 of 20, got %d of %d", len(searchReaders),
+				calc.SubsetSize(), calc.SupersetSize())
+		}
+		if calc.Buckets()[1].Count() != 4 || calc.BackgroundCount("outage") != 5 {
+			t.Errorf("%d readers: expected outage in 4 of 5, got %d of %d", len(searchReaders),
+				calc.Buckets()[1].Count(), calc.BackgroundCount("outage"))
+		}
+		if math.Abs(calc.Score("mostly-a")-0.8) > 1e-9 || math.Abs(calc.Score("outage")-0.24) > 1e-9 {
+			t.Errorf("%d readers: expected scores 0.8 and 0.24, got %f and %f", len(searchReaders),
+				calc.Score("mostly-a"), calc.Score("outage"))
+		}
+	}
+}
+
+func TestTopHitsAggregation(t *testing.T) {
+	products := []struct {
+		id    string
+		brand string
+		price float64
+	}{
+		{"p1", "acme", 10},
+		{"p2", "acme", 30},
+		{"p3", "acme", 20},
+		{"p4", "globex", 5},
+		{"p5", "globex", 50},
+	}
+	var first, second []*Document
+	for i, product := range products {
+		doc := NewDocument(product.id).
+			AddField(NewKeywordField("brand", product.brand).Aggregatable()).
+			AddField(NewNumericField("price", product.price).Sortable()).
+			AddField(NewTextField("name", "product "+product.id).StoreValue())
+		if i%2 == 0 {
+			first = append(first, doc)
+		} else {
+			second = append(second, doc)
+		}
+	}
+
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+	writers := []*Writer{
+		openTestWriterWithDocs(t, tmpIndexPath, first...),
+		openTestWriterWithDocs(t, tmpIndexPath2, second...),
+	}
+	var readers []*Reader
+	for _, writer := range writers {
+		reader, err := writer.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, reader)
+	}
+	defer func() {
+		for i := range writers {
+			_ = readers[i].Close()
+			_ = writers[i].Close()
+		}
+	}()
+
+	brands := aggregations.NewTermsAggregation(search.Field("brand"), 10)
+	brands.AddAggregation("top", aggregations.TopHits(2, search.SortOrder{
+		search.SortBy(search.Field("price")).Desc(),
+	}).StoredFields("name"))
+	req := NewTopNSearch(10, NewMatchAllQuery())
+	req.AddAggregation("brands", brands)
+	dmi, err := MultiSearch(context.Background(), req, readers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string][]string{
+		"acme":   {"product p2", "product p3"},
+		"globex": {"product p5", "product p4"},
+	}
+	buckets := dmi.Aggregations().Buckets("brands")
+	if len(buckets) != len(expect) {
+		t.Fatalf("expected %d brands, got %d", len(expect), len(buckets))
+	}
+	for _, bucket := range buckets {
+		calc := bucket.Aggregation("top").(*aggregations.TopHitsCalculator)
+		hits, err := calc.Hits()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, hit := range hits {
+			names = append(names, hit.Document.Text("name"))
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expect[bucket.Name()]) {
+			t.Errorf("expected top hits %v for %s, got %v", expect[bucket.Name()], bucket.Name(), names)
+		}
+		// the hits remain usable after the search reused its matches
+		doc, err := hits[0].Match.Document("name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Text("name") != expect[bucket.Name()][0] {
+			t.Errorf("expected %s, got %s", expect[bucket.Name()][0], doc.Text("name"))
+		}
+	}
+}
+
+func TestFiltersAggregation(t *testing.T) {
+	logs := []struct {
+		id     string
+		level  string
+		region string
+		bytes  float64
+	}{
+		{"l1", "error", "eu", 10},
+		{"l2", "warning", "us", 20},
+		{"l3", "error", "us", 30},
+		{"l4", "info", "eu", 40},
+		{"l5", "info", "us", 50},
+		{"l6", "warning", "eu", 60},
+	}
+	var first, second []*Document
+	for i, log := range logs {
+		doc := NewDocument(log.id).
+			AddField(NewKeywordField("level", log.level)).
+			AddField(NewKeywordField("region", log.region)).
+			AddField(NewNumericField("bytes", log.bytes).Sortable()).
+			AddField(NewCompositeFieldIncluding("_all", []string{"region"}))
+		if i < 4 {
+			first = append(first, doc)
+		} else {
+			second = append(second, doc)
+		}
+	}
+
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+	tmpIndexPath2 := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath2)
+	writers := []*Writer{
+		openTestWriterWithDocs(t, tmpIndexPath, first...),
+		openTestWriterWithDocs(t, tmpIndexPath2, second...),
+	}
+	var readers []*Reader
+	for _, writer := range writers {
+		reader, err := writer.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, reader)
+	}
+	defer func() {
+		for i := range writers {
+			_ = readers[i].Close()
+			_ = writers[i].Close()
+		}
+	}()
+
+	filters := Filters(map[string]Query{
+		"errors":   NewTermQuery("error").SetField("level"),
+		"warnings": NewTermQuery("warning").SetField("level"),
+		// the default search field
+		"eu": NewTermQuery("eu"),
+	}).OtherBucket("other").
+		AddAggregation("bytes", aggregations.Sum(search.Field("bytes")))
+	req := NewTopNSearch(10, NewMatchAllQuery())
+	req.AddAggregation("filters", filters)
+	dmi, err := MultiSearch(context.Background(), req, readers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct {
+		name  string
+		count uint64
+		bytes float64
+	}{
+		{"errors", 2, 40},
+		{"eu", 3, 110},
+		{"warnings", 2, 80},
+		{"other", 1, 50},
+	}
+	buckets := dmi.Aggregations().Buckets("filters")
+	if len(buckets) != len(expect) {
+		t.Fatalf("expected %d buckets, got %d", len(expect), len(buckets))
+	}
+	for i, bucket := range buckets {
+		if bucket.Name() != expect[i].name {
+			t.Errorf("expected bucket %d to be %s, got %s", i, expect[i].name, bucket.Name())
+		}
+		if bucket.Count() != expect[i].count {
+			t.Errorf("expected %s count %d, got %d", bucket.Name(), expect[i].count, bucket.Count())
+		}
+		if bucket.Metric("bytes") != expect[i].bytes {
+			t.Errorf("expected %s bytes %f, got %f", bucket.Name(), expect[i].bytes, bucket.Metric("bytes"))
+		}
+	}
+}
+
+func TestFiltersAggregationReaderConfig(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	config := DefaultConfig(tmpIndexPath)
+	config.DefaultSearchField = "region"
+	writer, err := OpenWriter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = writer.Close()
+	}()
+	batch := NewBatch()
+	for i, region := range []string{"eu", "us", "eu"} {
+		doc := NewDocument(fmt.Sprintf("d%d", i)).
+			AddField(NewKeywordField("region", region))
+		batch.Update(doc.ID(), doc)
+	}
+	err = writer.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := writer.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// the query has no field, so the default search field of the reader is used
+	req := NewAllMatches(NewMatchAllQuery())
+	req.AddAggregation("filters", Filters(map[string]Query{
+		"eu": NewTermQuery("eu"),
+	}))
+	dmi, err := reader.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := dmi.Aggregations().Buckets("filters")
+	if len(buckets) != 1 || buckets[0].Count() != 2 {
+		t.Errorf("expected 2 documents in eu, got %v", buckets)
+	}
+
+	// a query which cannot be evaluated fails the search
+	req = NewAllMatches(NewMatchAllQuery())
+	req.AddAggregation("filters", Filters(map[string]Query{
+		"invalid": NewRegexpQuery("[").SetField("region"),
+	}))
+	dmi, err = reader.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err = dmi.Next()
+	for err == nil && next != nil {
+		next, err = dmi.Next()
+	}
+	if err == nil {
+		t.Error("expected the error of the invalid filter")
+	}
+}
+
+// opaqueReader hides the snapshot it wraps
+type opaqueReader struct {
+	search.Reader
+}
+
+func TestFiltersAggregationReaders(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	var docs []*Document
+	for i := 0; i < 6; i++ {
+		parity := "even"
+		if i%2 == 1 {
+			parity = "odd"
+		}
+		docs = append(docs, NewDocument(fmt.Sprintf("d%d", i)).
+			AddField(NewKeywordField("parity", parity)))
+	}
+	writer := openTestWriterWithDocs(t, tmpIndexPath, docs...)
+	defer func() {
+		_ = writer.Close()
+	}()
+	reader, err := writer.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	odd, err := reader.reader.TermBitmaps([]byte("odd"), "parity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, matchReader := range []search.MatchReader{
+		&globalStatsReader{Reader: reader.reader, stats: newGlobalStats()},
+		&opaqueReader{Reader: reader.reader},
+	} {
+		calc := Filters(map[string]Query{
+			"odd": NewTermQuery("odd").SetField("parity"),
+		}).OtherBucket("rest").Calculator().(*FiltersCalculator)
+		// the documents need not be consumed in order
+		for _, number := range []uint64{0, 1, 3, 4, 5, 2} {
+			match := &search.DocumentMatch{Number: number}
+			match.SetReader(matchReader)
+			calc.Consume(match)
+		}
+		calc.Finish()
+		if calc.Err() != nil {
+			t.Fatal(calc.Err())
+		}
+		buckets := calc.Buckets()
+		if buckets[0].Count() != odd.Count() || buckets[1].Count() != 6-odd.Count() {
+			t.Errorf("%T: expected %d odd documents, got %d and %d others", matchReader,
+				odd.Count(), buckets[0].Count(), buckets[1].Count())
+		}
+	}
+}
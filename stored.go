@@ -0,0 +1,79 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// Document returns a typed view of the stored fields of the document
+// with the provided number, limited to the listed fields, or all of them
+// if none are listed.  Values are decoded using the field types recorded
+// when the document was indexed, for the fields using StoreValueType.
+func (r *Reader) Document(number uint64, fields ...string) (*search.StoredDocument, error) {
+	return search.LoadStoredDocument(r.reader, number, fields...)
+}
+
+// DocumentByID returns a typed view of the stored fields of the document
+// with the provided identifier, or nil if there is no such document
+func (r *Reader) DocumentByID(id string, fields ...string) (*search.StoredDocument, error) {
+	match, err := r.Get(Identifier(id))
+	if err != nil || match == nil {
+		return nil, err
+	}
+	return match.Document(fields...)
+}
+
+// Get returns the live document with the identifier, or nil if there is
+// no such document.  The identifier is looked up directly in the term
+// dictionary of each segment, without running a search.  The stored fields
+// of the returned match can be visited, and the values of the listed doc
+// value fields are loaded.
+func (r *Reader) Get(id segment.Term, docValueFields ...string) (*search.DocumentMatch, error) {
+	matches, err := r.MultiGet([]segment.Term{id}, docValueFields...)
+	if err != nil {
+		return nil, err
+	}
+	return matches[0], nil
+}
+
+// MultiGet returns the live documents with the identifiers, in the same
+// order, with nil for the identifiers not found
+func (r *Reader) MultiGet(ids []segment.Term, docValueFields ...string) ([]*search.DocumentMatch, error) {
+	numbers, found, err := r.reader.DocumentNumbers(ids)
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]*search.DocumentMatch, len(ids))
+	for idIndex := range ids {
+		if !found[idIndex] {
+			continue
+		}
+		match := &search.DocumentMatch{
+			Number: numbers[idIndex],
+		}
+		match.SetReader(r.reader)
+		if len(docValueFields) > 0 {
+			err = match.LoadDocumentValues(search.NewSearchContext(1, 0), docValueFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+		rv[idIndex] = match
+	}
+	return rv, nil
+}
@@ -0,0 +1,307 @@
+//  Copyright (c) 2020 The Bluge Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bluge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	segment "github.com/strivewrt/bluge_segment_api"
+
+	"github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/search"
+)
+
+func TestStoredDocument(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	indexWriter, err := OpenWriter(DefaultConfig(tmpIndexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	created := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	doc := NewDocument("a").
+		AddField(NewTextField("title", "hello world").StoreValueType()).
+		AddField(NewNumericField("n", 1.5).StoreValueType()).
+		AddField(NewNumericField("n", -3).StoreValueType()).
+		AddField(NewDateTimeField("created", created).StoreValueType()).
+		AddField(NewGeoPointField("loc", -122.1, 37.4).StoreValueType()).
+		AddField(NewStoredOnlyField("raw", []byte{0x00, 0x01}).StoreValueType()).
+		AddField(NewKeywordField("plain", "y").StoreValue()).
+		AddField(NewKeywordField("unstored", "x"))
+	other := NewDocument("b").
+		AddField(NewNumericField("n", 7).StoreValue())
+	batch := NewBatch()
+	batch.Update(doc.ID(), doc)
+	batch.Update(other.ID(), other)
+	err = indexWriter.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexWriter.Delete(other.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	stored, err := indexReader.DocumentByID("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil {
+		t.Fatal("expected document a")
+	}
+	expectFields := []string{"_id", "created", "loc", "n", "plain", "raw", "title"}
+	gotFields := append([]string(nil), stored.Fields()...)
+	sort.Strings(gotFields)
+	if !reflect.DeepEqual(gotFields, expectFields) {
+		t.Errorf("expected fields %v, got %v", expectFields, gotFields)
+	}
+	if stored.ID() != "a" {
+		t.Errorf("expected id a, got %s", stored.ID())
+	}
+	if stored.Text("title") != "hello world" || stored.Type("title") != search.FieldTypeText {
+		t.Errorf("unexpected title %q of type %v", stored.Text("title"), stored.Type("title"))
+	}
+	if !reflect.DeepEqual(stored.Numbers("n"), []float64{1.5, -3}) {
+		t.Errorf("expected numbers [1.5 -3], got %v", stored.Numbers("n"))
+	}
+	if !stored.Date("created").Equal(created) {
+		t.Errorf("expected created %v, got %v", created, stored.Date("created"))
+	}
+	loc := stored.GeoPoint("loc")
+	if loc == nil || math.Abs(loc.Lon+122.1) > 1e-6 || math.Abs(loc.Lat-37.4) > 1e-6 {
+		t.Errorf("expected loc -122.1,37.4, got %v", loc)
+	}
+	if !reflect.DeepEqual(stored.Value("raw"), []byte{0x00, 0x01}) || stored.Type("raw") != search.FieldTypeBytes {
+		t.Errorf("unexpected raw %v of type %v", stored.Value("raw"), stored.Type("raw"))
+	}
+	if stored.Text("plain") != "y" || stored.Type("plain") != search.FieldTypeUnknown {
+		t.Errorf("unexpected plain %q of type %v", stored.Text("plain"), stored.Type("plain"))
+	}
+	// the recorded types are not visible as a stored field
+	err = indexReader.VisitStoredFields(stored.DocumentNumber(), func(field string, value []byte) bool {
+		if field == index.StoredFieldTypesField {
+			t.Errorf("expected stored field types to be hidden")
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// values are not decoded as a type other than the recorded one
+	if len(stored.Dates("n")) != 0 || len(stored.Numbers("created")) != 0 {
+		t.Errorf("expected values of other types to be skipped")
+	}
+	if _, ok := stored.Interface("created").(time.Time); !ok {
+		t.Errorf("expected created to convert to a time, got %T", stored.Interface("created"))
+	}
+
+	deleted, err := indexReader.DocumentByID("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != nil {
+		t.Errorf("expected deleted document to not be found")
+	}
+
+	dmi, err := indexReader.Search(context.Background(), NewTopNSearch(10, NewTermQuery("hello").SetField("title")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := dmi.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match == nil {
+		t.Fatal("expected a match")
+	}
+	selected, err := match.Document("n", "loc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected.Fields(), []string{"loc", "n"}) {
+		t.Errorf("expected only selected fields, got %v", selected.Fields())
+	}
+	if selected.Number("n") != 1.5 {
+		t.Errorf("expected first number 1.5, got %f", selected.Number("n"))
+	}
+}
+
+func TestReaderMultiGet(t *testing.T) {
+	tmpIndexPath := createTmpIndexPath(t)
+	defer cleanupTmpIndexPath(t, tmpIndexPath)
+
+	indexWriter, err := OpenWriter(DefaultConfig(tmpIndexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexWriter.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	// several batches, to build several segments
+	for b := 0; b < 3; b++ {
+		batch := NewBatch()
+		for i := b * 10; i < (b+1)*10; i++ {
+			doc := NewDocument(fmt.Sprintf("doc-%02d", i)).
+				AddField(NewNumericField("version", 1).StoreValue())
+			batch.Update(doc.ID(), doc)
+		}
+		err = indexWriter.Batch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// an update leaves the old version deleted in an older segment
+	updated := NewDocument("doc-03").AddField(NewNumericField("version", 2).StoreValue())
+	err = indexWriter.Update(updated.ID(), updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexWriter.Delete(Identifier("doc-15"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexReader, err := indexWriter.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cerr := indexReader.Close()
+		if cerr != nil {
+			t.Fatal(cerr)
+		}
+	}()
+
+	ids := []segment.Term{
+		Identifier("doc-25"),
+		Identifier("doc-15"),
+		Identifier("missing"),
+		Identifier("doc-03"),
+		Identifier("doc-00"),
+	}
+	matches, err := indexReader.MultiGet(ids, "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []float64{1, -1, -1, 2, 1}
+	for i, match := range matches {
+		if expect[i] < 0 {
+			if match != nil {
+				t.Errorf("expected %s to not be found", ids[i].Term())
+			}
+			continue
+		}
+		if match == nil {
+			t.Errorf("expected %s to be found", ids[i].Term())
+			continue
+		}
+		doc, err := match.Document()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.ID() != string(ids[i].Term()) {
+			t.Errorf("expected %s, got %s", ids[i].Term(), doc.ID())
+		}
+		if doc.Number("version") != expect[i] {
+			t.Errorf("expected %s version %f, got %f", ids[i].Term(), expect[i], doc.Number("version"))
+		}
+		version, err := DecodeNumericFloat64(search.Field("version").Value(match))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expect[i] {
+			t.Errorf("expected %s doc value version %f, got %f", ids[i].Term(), expect[i], version)
+		}
+	}
+
+	match, err := indexReader.Get(Identifier("doc-03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match == nil || match.Number != matches[3].Number {
+		t.Errorf("expected Get to find the same document as MultiGet")
+	}
+}
+
+func TestStoredFieldTypesEmptyDocument(t *testing.T) {
+	var count int
+	Document{}.EachField(func(field segment.Field) {
+		count++
+	})
+	if count != 0 {
+		t.Errorf("expected no fields, got %d", count)
+	}
+}
+
+func TestStoredFieldTypesOncePerDocument(t *testing.T) {
+	doc := NewDocument("a").
+		AddField(NewNumericField("n", 1).StoreValueType()).
+		AddField(NewNumericField("n", 2).StoreValueType()).
+		AddField(NewKeywordField("k", "x").StoreValue())
+	typeFields := func() []segment.Field {
+		var rv []segment.Field
+		doc.EachField(func(field segment.Field) {
+			if field.Name() == index.StoredFieldTypesField {
+				rv = append(rv, field)
+			}
+		})
+		return rv
+	}
+	first := typeFields()
+	if len(first) != 1 {
+		t.Fatalf("expected 1 type field, got %d", len(first))
+	}
+	// the same hidden field is visited again rather than built again
+	if second := typeFields(); second[0] != first[0] {
+		t.Errorf("expected the type field to be built once")
+	}
+
+	var count int
+	NewDocument("b").AddField(NewKeywordField("k", "x").StoreValue()).EachField(func(field segment.Field) {
+		count++
+	})
+	if count != 2 {
+		t.Errorf("expected only the fields added, got %d", count)
+	}
+}